@@ -19,14 +19,77 @@ type Range interface {
 	Union(right Range) Range
 }
 
+// Hooks contains the optional callbacks invoked after the tree has been
+// modified. Each callback receives the old and the new element, a nil
+// callback is simply ignored.
+type Hooks struct {
+	// OnInsert is called when a new element is added. The old is always nil.
+	OnInsert func(old, new Range)
+
+	// OnUnion is called when an inserted value is merged into the existing
+	// element old via Range.Union, and new is the merged result.
+	OnUnion func(old, new Range)
+
+	// OnDelete is called when the element old is removed from the tree.
+	// The new is always nil.
+	//
+	// It's also called by Insert, for each neighbor absorbed by an element
+	// which has grown by Range.Union to overlap it, before the OnUnion of
+	// that element.
+	OnDelete func(old, new Range)
+}
+
+// Subtracter is an optional extension of Range, which is required by
+// Tree.RemoveRange.
+type Subtracter interface {
+	Range
+
+	// Subtract returns the parts of current element which are not in the
+	// right, in order. It returns nil if the right contains current element.
+	// The returned parts must not overlap the right.
+	Subtract(right Range) []Range
+}
+
 // Tree is a high-performance AVL tree.
 type Tree struct {
-	root *avlNode
+	root  *avlNode
+	hooks Hooks
+
+	// aug is used by the augmented trees, such as MerkleTree, nil by default.
+	aug augmentFunc
+
+	// ver is increased on every modification of the tree.
+	ver  uint64
+	size int
+}
+
+// SetHooks sets the callbacks to be invoked on every modification of the tree.
+// The hooks are called synchronously, after the tree has been modified.
+func (t *Tree) SetHooks(h Hooks) {
+	t.hooks = h
 }
 
 // Insert a new Range into the AVL tree.
 func (t *Tree) Insert(val Range) {
-	t.root = t.root.insert(val)
+	var x *avlNode
+	var old Range
+	t.root, x, old = t.root.insert(val, t.aug)
+	switch {
+	case x == nil: // nothing changed
+	case old == nil:
+		t.ver++
+		t.size++
+		if t.hooks.OnInsert != nil {
+			t.hooks.OnInsert(nil, x.val)
+		}
+	default:
+		t.ver++
+		t.absorb(x)
+		x.augmentUp(t.aug)
+		if t.hooks.OnUnion != nil {
+			t.hooks.OnUnion(old, x.val)
+		}
+	}
 }
 
 // Search returns true if the AVL tree contains the <val>.
@@ -34,6 +97,124 @@ func (t *Tree) Search(val Range) bool {
 	return t.root.search(val)
 }
 
+// Get returns the element which contains the <val>, that is, the element
+// Search(val) would have found. It returns false if there is no such element.
+func (t *Tree) Get(val Range) (Range, bool) {
+	x := t.root.find(val)
+	if x == nil || !x.val.Contains(val) {
+		return nil, false
+	}
+	return x.val, true
+}
+
+// SearchFunc returns the element for which cmp returns 0. The cmp reports how
+// an element is ordered against the target: a negative number if the element
+// is before the target, a positive number if after, and 0 if the element
+// contains the target. It returns false if there is no such element.
+//
+// Unlike Search, the target needn't be a Range, which helps to avoid the
+// allocation of a Range for every lookup.
+func (t *Tree) SearchFunc(cmp func(Range) int) (Range, bool) {
+	for n := t.root; n != nil; {
+		switch factor := cmp(n.val); {
+		case factor < 0:
+			n = n.right
+		case factor > 0:
+			n = n.left
+		default:
+			return n.val, true
+		}
+	}
+	return nil, false
+}
+
+// Len returns the number of elements in the AVL tree.
+func (t *Tree) Len() int {
+	return t.size
+}
+
+// Delete removes the element which contains the <val>, that is, the element
+// Search(val) would have found. It returns false if there is no such element.
+func (t *Tree) Delete(val Range) bool {
+	x := t.root.find(val)
+	if x == nil || !x.val.Contains(val) {
+		return false
+	}
+
+	t.remove(x)
+	return true
+}
+
+// RemoveRange removes the <val> from every element overlapping it, that is,
+// the elements equal to the <val> according to Range.Compare. An element is
+// trimmed or split into pieces by Subtracter.Subtract, and it will be deleted
+// if the <val> contains it. It returns false if no element overlaps the <val>.
+//
+// All the elements overlapping the <val> must implement Subtracter, or it
+// panics.
+func (t *Tree) RemoveRange(val Range) bool {
+	var elems []Range
+	t.root.overlapping(val, func(elem Range) bool {
+		elems = append(elems, elem)
+		return true
+	})
+
+	// validate all the pieces before modifying the tree, so that the tree is
+	// untouched if it panics
+	pieces := make([][]Range, len(elems))
+	for i, elem := range elems {
+		s, ok := elem.(Subtracter)
+		if !ok {
+			panic("avl: RemoveRange on an element not implementing Subtracter")
+		}
+		pieces[i] = s.Subtract(val)
+		for _, piece := range pieces[i] {
+			if piece.Compare(val) == 0 {
+				panic("avl: Subtract returns a piece overlapping the removed range")
+			}
+		}
+	}
+
+	for i, elem := range elems {
+		t.remove(t.root.find(elem))
+		for _, piece := range pieces[i] {
+			t.Insert(piece)
+		}
+	}
+	return len(elems) != 0
+}
+
+// absorb merges the neighbors overlapping the node x into x, which may happen
+// after x has grown by Range.Union. The absorbed neighbors are deleted.
+func (t *Tree) absorb(x *avlNode) {
+	for {
+		n := x.prev()
+		if n == nil || n.val.Compare(x.val) != 0 {
+			if n = x.next(); n == nil || n.val.Compare(x.val) != 0 {
+				return
+			}
+		}
+
+		x.val = n.val.Union(x.val)
+		t.remove(n)
+	}
+}
+
+// remove the node x from the tree.
+func (t *Tree) remove(x *avlNode) {
+	old := x.val
+	t.root = x.remove(t.aug)
+	t.ver++
+	t.size--
+	if t.hooks.OnDelete != nil {
+		t.hooks.OnDelete(old, nil)
+	}
+}
+
+// augmentFunc recomputes the augmented data of a node, which is usually kept
+// in the node value, from the node value and the children of the node.
+type augmentFunc func(n *avlNode)
+
 type avlNode struct {
 	val Range
 
@@ -98,7 +279,33 @@ func (n *avlNode) rotateRightLeft() *avlNode {
 	return n.rotateLeft()
 }
 
-func (n *avlNode) rebalance() (p *avlNode) {
+// augment recomputes the augmented data for the children of current node and
+// then the current node itself. It's a no-op if aug is nil.
+func (n *avlNode) augment(aug augmentFunc) {
+	if aug == nil {
+		return
+	}
+	if n.left != nil {
+		aug(n.left)
+	}
+	if n.right != nil {
+		aug(n.right)
+	}
+	aug(n)
+}
+
+// augmentUp recomputes the augmented data for the current node and all of its
+// ancestors. It's a no-op if aug is nil.
+func (n *avlNode) augmentUp(aug augmentFunc) {
+	if aug == nil {
+		return
+	}
+	for ; n != nil; n = n.parent {
+		aug(n)
+	}
+}
+
+func (n *avlNode) rebalance(aug augmentFunc) (p *avlNode) {
 	for p = n.parent; p != nil; n, p = p, p.parent {
 		grandParant, oldHeight := p.parent, p.height()
 		leftChild := grandParant != nil && grandParant.left == p
@@ -116,6 +323,8 @@ func (n *avlNode) rebalance() (p *avlNode) {
 				p = p.rotateLeft()
 			}
 		}
+		// the children may have been rotated
+		p.augment(aug)
 
 		p.parent = grandParant
 		if grandParant == nil {
@@ -134,46 +343,160 @@ func (n *avlNode) rebalance() (p *avlNode) {
 		}
 	}
 
+	p.parent.augmentUp(aug)
 	for p.parent != nil {
 		p = p.parent
 	}
 	return
 }
 
+// fixup walks from the current node up to the root, and restores the AVL
+// property for every node on the path. Unlike rebalance, it never stops early,
+// which is required after a removal. It returns the new root of the AVL tree.
+func (n *avlNode) fixup(aug augmentFunc) (root *avlNode) {
+	for p := n; p != nil; p = p.parent {
+		grandParant := p.parent
+		leftChild := grandParant != nil && grandParant.left == p
+		switch factor := p.left.height() - p.right.height(); {
+		case factor > 1: // left heavy
+			if p.left.left.height() < p.left.right.height() {
+				p = p.rotateLeftRight()
+			} else {
+				p = p.rotateRight()
+			}
+		case factor < -1: // right heavy
+			if p.right.right.height() < p.right.left.height() {
+				p = p.rotateRightLeft()
+			} else {
+				p = p.rotateLeft()
+			}
+		}
+		p.augment(aug)
+
+		p.parent = grandParant
+		if grandParant != nil {
+			if leftChild {
+				grandParant.left = p
+			} else {
+				grandParant.right = p
+			}
+		}
+		p.updateHeight()
+		root = p
+	}
+	return
+}
+
 // insert a new <val> and return the new root of the AVL tree.
-func (n *avlNode) insert(val Range) *avlNode {
+// The x is the node holding the <val>, or nil if the tree didn't change.
+// If the <val> had been merged into an existing node, old is the previous
+// value of that node.
+func (n *avlNode) insert(val Range, aug augmentFunc) (root, x *avlNode, old Range) {
 	z := &avlNode{val: val}
+	if aug != nil {
+		aug(z)
+	}
 	if n == nil {
-		return z
+		return z, z, nil
 	}
 
-	for x := n; ; {
+	for x = n; ; {
 		switch factor := x.val.Compare(val); {
 		case factor < 0: // x < z
 			if x.right == nil {
 				x.right, z.parent = z, x
-				return z.rebalance()
+				return z.rebalance(aug), z, nil
 			}
 			x = x.right
 
 		case factor > 0: // x > z
 			if x.left == nil {
 				x.left, z.parent = z, x
-				return z.rebalance()
+				return z.rebalance(aug), z, nil
 			}
 			x = x.left
 
 		default: // x == z
-			if !x.val.Contains(val) {
-				x.val = x.val.Union(val)
+			if x.val.Contains(val) {
+				return n, nil, nil
 			}
+			old, x.val = x.val, x.val.Union(val)
+			return n, x, old
+		}
+	}
+}
+
+// remove the current node from the AVL tree, and return the new root.
+// The other nodes keep holding the same values.
+func (n *avlNode) remove(aug augmentFunc) *avlNode {
+	p := n.parent
+	var child, lowest *avlNode
+	switch {
+	case n.left == nil:
+		child, lowest = n.right, p
+	case n.right == nil:
+		child, lowest = n.left, p
+	default:
+		// move the in-order successor, which has no left child, to the
+		// position of current node.
+		child = n.right.leftmost()
+		lowest = child
+		if child != n.right {
+			lowest = child.parent
+			lowest.left = child.right
+			if child.right != nil {
+				child.right.parent = lowest
+			}
+			child.right = n.right
+			child.right.parent = child
+		}
+		child.left = n.left
+		child.left.parent = child
+	}
+
+	if child != nil {
+		child.parent = p
+	}
+	if p != nil {
+		if p.left == n {
+			p.left = child
+		} else {
+			p.right = child
+		}
+	}
+	n.parent, n.left, n.right = nil, nil, nil
+
+	if lowest == nil {
+		// the root is removed, and the child is the new root
+		return child
+	}
+	return lowest.fixup(aug)
+}
+
+// find returns the node which is equal to the <val>, or nil if not found.
+func (n *avlNode) find(val Range) *avlNode {
+	for n != nil {
+		switch factor := n.val.Compare(val); {
+		case factor < 0: // n < z
+			n = n.right
+		case factor > 0: // n > z
+			n = n.left
+		default: // n == z
 			return n
 		}
 	}
+	return nil
 }
 
 // search returns true if the AVL tree contains the <val>.
 func (n *avlNode) search(val Range) bool {
+	x := n.find(val)
+	return x != nil && x.val.Contains(val)
+}
+
+// overlapping calls fn for all the elements equal to the <val> in order,
+// until fn returns false. It returns false if the iteration was stopped.
+func (n *avlNode) overlapping(val Range, fn func(Range) bool) bool {
 	for n != nil {
 		switch factor := n.val.Compare(val); {
 		case factor < 0: // n < z
@@ -181,10 +504,11 @@ func (n *avlNode) search(val Range) bool {
 		case factor > 0: // n > z
 			n = n.left
 		default: // n == z
-			return n.val.Contains(val)
+			return n.left.overlapping(val, fn) && fn(n.val) &&
+				n.right.overlapping(val, fn)
 		}
 	}
-	return false
+	return true
 }
 
 // DebugPreorder will traverse the tree in preorder. For debug-use only.
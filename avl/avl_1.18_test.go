@@ -0,0 +1,48 @@
+//go:build go1.18
+// +build go1.18
+
+package avl_test
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/sym01/algo/avl"
+	"github.com/sym01/algo/avl/avltest"
+)
+
+func genIntRange(rnd *rand.Rand) avl.Range {
+	min := rnd.Intn(500)
+	return &intRange{min, min + rnd.Intn(20)}
+}
+
+func TestTree_model(t *testing.T) {
+	avltest.Test(t, avltest.Config{
+		Gen:   genIntRange,
+		Runs:  200,
+		Steps: 500,
+	})
+}
+
+// decodeOps decodes every 3 bytes of data as an operation.
+func decodeOps(data []byte) (ops []avltest.Op) {
+	for ; len(data) >= 3; data = data[3:] {
+		min := int(data[1])
+		ops = append(ops, avltest.Op{
+			Kind: avltest.OpKind(data[0] % 3),
+			Val:  &intRange{min, min + int(data[2]%16)},
+		})
+	}
+	return
+}
+
+func FuzzTree(f *testing.F) {
+	f.Add([]byte("\x00\x0a\x05\x00\x14\x05\x00\x0e\x08\x01\x10\x00\x02\x0c\x00"))
+	f.Add([]byte("\x00\x01\x00\x00\x02\x00\x00\x03\x00\x02\x02\x00\x01\x02\x00"))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := avltest.Replay(decodeOps(data), nil); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
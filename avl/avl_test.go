@@ -2,6 +2,7 @@ package avl_test
 
 import (
 	"fmt"
+	"math/rand"
 	"testing"
 
 	"github.com/sym01/algo/avl"
@@ -50,3 +51,299 @@ func TestBytesTree(t *testing.T) {
 	}
 
 }
+
+func TestTree_Delete(t *testing.T) {
+	tree := new(avl.IntTree)
+	if tree.Delete(1) {
+		t.Fatal("unexpected result for deleting from an empty tree")
+	}
+
+	rnd := rand.New(rand.NewSource(1))
+	perm := rnd.Perm(1000)
+	for _, i := range perm {
+		tree.Insert(i)
+	}
+
+	deleted := make(map[int]bool)
+	for _, i := range perm[:500] {
+		if !tree.Delete(i) {
+			t.Fatalf("unexpected result for deleting %d", i)
+		}
+		deleted[i] = true
+	}
+	if tree.Delete(perm[0]) {
+		t.Fatalf("unexpected result for deleting %d twice", perm[0])
+	}
+
+	for i := 0; i < 1000; i++ {
+		if ret := tree.Search(i); ret == deleted[i] {
+			t.Fatalf("unexpected result for %d, expect %v, got %v", i, !deleted[i], ret)
+		}
+	}
+}
+
+func TestTree_SetHooks(t *testing.T) {
+	tree := new(avl.Tree)
+	var events []string
+	record := func(op string) func(old, new avl.Range) {
+		return func(old, new avl.Range) {
+			events = append(events, fmt.Sprintf("%s:%v->%v", op, old, new))
+		}
+	}
+	tree.SetHooks(avl.Hooks{
+		OnInsert: record("insert"),
+		OnUnion:  record("union"),
+		OnDelete: record("delete"),
+	})
+
+	tree.Insert(&intRange{10, 15})
+	tree.Insert(&intRange{11, 12}) // contained, nothing changed
+	tree.Insert(&intRange{14, 20})
+	tree.Delete(&intRange{12, 12})
+	tree.Delete(&intRange{12, 12}) // not found
+
+	expected := []string{
+		"insert:<nil>->&{10 15}",
+		"union:&{10 15}->&{10 20}",
+		"delete:&{10 20}-><nil>",
+	}
+	if fmt.Sprint(events) != fmt.Sprint(expected) {
+		t.Fatalf("unexpected events, expect %v, got %v", expected, events)
+	}
+}
+
+func TestTree_Insert_absorbNeighbors(t *testing.T) {
+	tree := new(avl.Tree)
+	var events []string
+	record := func(op string) func(old, new avl.Range) {
+		return func(old, new avl.Range) {
+			events = append(events, fmt.Sprintf("%s:%v->%v", op, old, new))
+		}
+	}
+	tree.Insert(&intRange{10, 15})
+	tree.Insert(&intRange{20, 25})
+	tree.SetHooks(avl.Hooks{
+		OnUnion:  record("union"),
+		OnDelete: record("delete"),
+	})
+
+	// [14, 21] overlaps both, and the grown element absorbs the other one
+	tree.Insert(&intRange{14, 21})
+	if tree.Len() != 1 {
+		t.Fatalf("expect 1 element, got %d", tree.Len())
+	}
+	if c := tree.Cursor(); !c.First() || fmt.Sprint(c.Value()) != "&{10 25}" {
+		t.Fatalf("unexpected element, got %v", c.Value())
+	}
+	for i := 10; i <= 25; i++ {
+		if !tree.Search(&intRange{i, i}) {
+			t.Fatalf("expect %d to be found", i)
+		}
+	}
+
+	expected := []string{
+		"delete:&{20 25}-><nil>",
+		"union:&{10 15}->&{10 25}",
+	}
+	if fmt.Sprint(events) != fmt.Sprint(expected) {
+		t.Fatalf("unexpected events, expect %v, got %v", expected, events)
+	}
+}
+
+func TestBytesTree_reuseBuffer(t *testing.T) {
+	tree := new(avl.BytesTree)
+	buf := make([]byte, 3)
+	for i := byte(1); i < 20; i++ {
+		buf[0], buf[1], buf[2] = i, i, i
+		tree.Insert(buf)
+	}
+
+	for i := byte(1); i < 20; i++ {
+		if !tree.Search([]byte{i, i, i}) {
+			t.Fatalf("unexpected result for %v after reusing the buffer", []byte{i, i, i})
+		}
+	}
+}
+
+func TestCompactBytesTree(t *testing.T) {
+	tree := new(avl.CompactBytesTree)
+	rnd := rand.New(rand.NewSource(1))
+	expected := make(map[string]bool)
+	buf := make([]byte, 0, 64)
+	for i := 0; i < 2000; i++ {
+		key := fmt.Sprintf("https://example.com/api/v%d/users/%d/profile", rnd.Intn(3), rnd.Intn(500))
+		buf = append(buf[:0], key...)
+		if rnd.Intn(4) == 0 {
+			if tree.Delete(buf) != expected[key] {
+				t.Fatalf("unexpected result for deleting %s", key)
+			}
+			delete(expected, key)
+		} else {
+			tree.Insert(buf)
+			expected[key] = true
+		}
+		// reuse the buffer
+		for i := range buf {
+			buf[i] = 0
+		}
+	}
+
+	for v := 0; v < 3; v++ {
+		for u := 0; u < 500; u++ {
+			key := fmt.Sprintf("https://example.com/api/v%d/users/%d/profile", v, u)
+			if ret := tree.Search([]byte(key)); ret != expected[key] {
+				t.Fatalf("unexpected result for %s, expect %v, got %v", key, expected[key], ret)
+			}
+		}
+	}
+	for _, key := range []string{"", "https://example.com/", "https://example.com/api/v1/users/1/profile/"} {
+		if tree.Search([]byte(key)) {
+			t.Fatalf("unexpected result for %s", key)
+		}
+	}
+}
+
+func TestTree_RemoveRange(t *testing.T) {
+	tree := new(avl.Tree)
+	if tree.RemoveRange(&intRange{1, 2}) {
+		t.Fatal("unexpected result for an empty tree")
+	}
+
+	// the expected state is recorded from the operations rather than the tree
+	covered := make(map[int]bool)
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		min := rnd.Intn(1000)
+		r := &intRange{min, min + rnd.Intn(20)}
+		remove := rnd.Intn(3) == 0
+		if remove {
+			tree.RemoveRange(r)
+		} else {
+			tree.Insert(r)
+		}
+		for j := r.min; j <= r.max; j++ {
+			covered[j] = !remove
+		}
+	}
+
+	for i := 0; i < 1000; i++ {
+		if ret := tree.Search(&intRange{i, i}); ret != covered[i] {
+			t.Fatalf("unexpected result for %d, expect %v, got %v", i, covered[i], ret)
+		}
+	}
+}
+
+// faultyRange is an intRange whose Subtract returns itself if faulty, which
+// overlaps the removed range.
+type faultyRange struct {
+	intRange
+	faulty bool
+}
+
+func asIntRange(r avl.Range) *intRange {
+	if f, ok := r.(*faultyRange); ok {
+		return &f.intRange
+	}
+	return r.(*intRange)
+}
+
+func (l *faultyRange) Compare(right avl.Range) int {
+	return l.intRange.Compare(asIntRange(right))
+}
+
+func (l *faultyRange) Contains(right avl.Range) bool {
+	return l.intRange.Contains(asIntRange(right))
+}
+
+func (l *faultyRange) Union(right avl.Range) avl.Range {
+	return l.intRange.Union(asIntRange(right))
+}
+
+func (l *faultyRange) Subtract(right avl.Range) []avl.Range {
+	if l.faulty {
+		return []avl.Range{l}
+	}
+	return l.intRange.Subtract(asIntRange(right))
+}
+
+func TestTree_RemoveRange_faultySubtracter(t *testing.T) {
+	tree := new(avl.Tree)
+	tree.Insert(&faultyRange{intRange: intRange{0, 10}})
+	tree.Insert(&faultyRange{intRange: intRange{20, 30}, faulty: true})
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("expect a panic for a piece overlapping the removed range")
+			}
+		}()
+		tree.RemoveRange(&intRange{5, 25})
+	}()
+
+	// the tree is untouched
+	if tree.Len() != 2 {
+		t.Fatalf("expect 2 elements, got %d", tree.Len())
+	}
+	for _, i := range []int{0, 5, 10, 20, 25, 30} {
+		if !tree.Search(&intRange{i, i}) {
+			t.Fatalf("expect %d to be found", i)
+		}
+	}
+}
+
+func TestTree_RemoveRange_notSubtracter(t *testing.T) {
+	tree := new(avl.IntTree)
+	tree.Insert(1)
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expect a panic for elements not implementing Subtracter")
+		}
+	}()
+	c := (*avl.Tree)(tree).Cursor()
+	c.First()
+	(*avl.Tree)(tree).RemoveRange(c.Value())
+}
+
+func TestTree_Get(t *testing.T) {
+	tree := new(avl.Tree)
+	tree.Insert(&intRange{10, 15})
+	tree.Insert(&intRange{20, 25})
+
+	if val, ok := tree.Get(&intRange{21, 22}); !ok || fmt.Sprint(val) != "&{20 25}" {
+		t.Fatalf("unexpected result for Get, got %v, %v", val, ok)
+	}
+	if val, ok := tree.Get(&intRange{14, 20}); ok || val != nil {
+		t.Fatalf("unexpected result for Get, got %v, %v", val, ok)
+	}
+}
+
+func TestTree_SearchFunc(t *testing.T) {
+	tree := new(avl.Tree)
+	tree.Insert(&intRange{10, 15})
+	tree.Insert(&intRange{20, 25})
+	tree.Insert(&intRange{30, 35})
+
+	search := func(target int) (avl.Range, bool) {
+		return tree.SearchFunc(func(val avl.Range) int {
+			r := val.(*intRange)
+			switch {
+			case r.max < target:
+				return -1
+			case r.min > target:
+				return 1
+			default:
+				return 0
+			}
+		})
+	}
+	if val, ok := search(22); !ok || fmt.Sprint(val) != "&{20 25}" {
+		t.Fatalf("unexpected result for SearchFunc, got %v, %v", val, ok)
+	}
+	if val, ok := search(35); !ok || fmt.Sprint(val) != "&{30 35}" {
+		t.Fatalf("unexpected result for SearchFunc, got %v, %v", val, ok)
+	}
+	if val, ok := search(18); ok || val != nil {
+		t.Fatalf("unexpected result for SearchFunc, got %v, %v", val, ok)
+	}
+}
@@ -0,0 +1,266 @@
+// Package avltest implements a model-based test harness for avl.Tree, which
+// can be used with any avl.Range implementation.
+//
+// The harness runs random sequences of Insert, Search and Delete against both
+// an avl.Tree and a naive reference model based on a sorted slice, and
+// reports the minimal sequence of operations which makes them diverge.
+package avltest
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sym01/algo/avl"
+)
+
+// OpKind is the kind of an operation.
+type OpKind int
+
+// The operations supported by the harness.
+const (
+	OpInsert OpKind = iota
+	OpSearch
+	OpDelete
+)
+
+func (k OpKind) String() string {
+	switch k {
+	case OpInsert:
+		return "Insert"
+	case OpSearch:
+		return "Search"
+	case OpDelete:
+		return "Delete"
+	default:
+		return fmt.Sprintf("OpKind(%d)", int(k))
+	}
+}
+
+// Op is an operation on the tree.
+type Op struct {
+	Kind OpKind
+	Val  avl.Range
+}
+
+func (o Op) String() string {
+	return fmt.Sprintf("%s(%v)", o.Kind, o.Val)
+}
+
+// Model is a naive reference implementation of avl.Tree, which keeps the
+// elements in a sorted slice. All the operations are O(n).
+//
+// Like avl.Tree, the elements in the model never overlap: an inserted value
+// is merged into the overlapping elements via avl.Range.Union.
+type Model struct {
+	elems []avl.Range
+}
+
+// Insert a new Range into the model.
+func (m *Model) Insert(val avl.Range) {
+	idx, found := m.find(val)
+	if !found {
+		m.elems = append(m.elems, nil)
+		copy(m.elems[idx+1:], m.elems[idx:])
+		m.elems[idx] = val
+		return
+	}
+	if m.elems[idx].Contains(val) {
+		return
+	}
+
+	merged := m.elems[idx].Union(val)
+	m.elems = append(m.elems[:idx], m.elems[idx+1:]...)
+	for idx, found = m.find(merged); found; idx, found = m.find(merged) {
+		merged = m.elems[idx].Union(merged)
+		m.elems = append(m.elems[:idx], m.elems[idx+1:]...)
+	}
+	m.elems = append(m.elems, nil)
+	copy(m.elems[idx+1:], m.elems[idx:])
+	m.elems[idx] = merged
+}
+
+// Search returns true if the model contains the <val>.
+func (m *Model) Search(val avl.Range) bool {
+	idx, found := m.find(val)
+	return found && m.elems[idx].Contains(val)
+}
+
+// Delete removes the element which contains the <val>, and returns false if
+// there is no such element.
+func (m *Model) Delete(val avl.Range) bool {
+	idx, found := m.find(val)
+	if !found || !m.elems[idx].Contains(val) {
+		return false
+	}
+	m.elems = append(m.elems[:idx], m.elems[idx+1:]...)
+	return true
+}
+
+// Elements returns all the elements in order.
+func (m *Model) Elements() []avl.Range {
+	return m.elems
+}
+
+// find returns the index of the first element overlapping the <val>, or the
+// index to insert the <val> at if not found.
+func (m *Model) find(val avl.Range) (int, bool) {
+	for idx, elem := range m.elems {
+		if factor := elem.Compare(val); factor >= 0 {
+			return idx, factor == 0
+		}
+	}
+	return len(m.elems), false
+}
+
+// Replay runs the operations against both an avl.Tree and a Model, and
+// returns an error describing the first divergence. The elements are compared
+// by equal, and reflect.DeepEqual will be used if equal is nil.
+func Replay(ops []Op, equal func(a, b avl.Range) bool) error {
+	if equal == nil {
+		equal = func(a, b avl.Range) bool { return reflect.DeepEqual(a, b) }
+	}
+
+	tree, model := new(avl.Tree), new(Model)
+	for step, op := range ops {
+		var got, expected bool
+		switch op.Kind {
+		case OpInsert:
+			tree.Insert(op.Val)
+			model.Insert(op.Val)
+			got, expected = true, true
+		case OpSearch:
+			got, expected = tree.Search(op.Val), model.Search(op.Val)
+		case OpDelete:
+			got, expected = tree.Delete(op.Val), model.Delete(op.Val)
+		default:
+			return fmt.Errorf("step %d: unknown operation %s", step, op)
+		}
+		if got != expected {
+			return fmt.Errorf("step %d: %s returns %v, expect %v", step, op, got, expected)
+		}
+
+		elems := model.Elements()
+		if tree.Len() != len(elems) {
+			return fmt.Errorf("step %d: %s: tree has %d elements, expect %d",
+				step, op, tree.Len(), len(elems))
+		}
+		c := tree.Cursor()
+		idx := 0
+		for ok := c.First(); ok; ok, idx = c.Next(), idx+1 {
+			if !equal(c.Value(), elems[idx]) {
+				return fmt.Errorf("step %d: %s: element #%d is %v, expect %v",
+					step, op, idx, c.Value(), elems[idx])
+			}
+		}
+	}
+	return nil
+}
+
+// Config is the configuration for Check.
+type Config struct {
+	// Gen generates a random Range. It's required.
+	Gen func(rnd *rand.Rand) avl.Range
+
+	// Equal compares two elements, reflect.DeepEqual will be used if nil.
+	Equal func(a, b avl.Range) bool
+
+	// Seed is the seed of the first run, and the following runs use the
+	// successive seeds.
+	Seed int64
+
+	// Runs is the number of random sequences, 100 will be used if <= 0.
+	Runs int
+
+	// Steps is the number of operations in each sequence, 100 will be used
+	// if <= 0.
+	Steps int
+}
+
+// Failure describes a minimal sequence of operations which makes the tree
+// and the model diverge.
+type Failure struct {
+	// Seed is the seed of the sequence before shrinking.
+	Seed int64
+
+	// Ops is the minimal failing sequence.
+	Ops []Op
+
+	// Err is the divergence reported by Replay for Ops.
+	Err error
+}
+
+func (f *Failure) Error() string {
+	ops := make([]string, 0, len(f.Ops))
+	for _, op := range f.Ops {
+		ops = append(ops, op.String())
+	}
+	return fmt.Sprintf("avltest: seed %d, minimal failing sequence [%s]: %s",
+		f.Seed, strings.Join(ops, ", "), f.Err)
+}
+
+// Check runs random sequences of operations against both an avl.Tree and a
+// Model. It returns nil if they always agree, otherwise a *Failure with a
+// minimal failing sequence.
+func Check(cfg Config) error {
+	runs, steps := cfg.Runs, cfg.Steps
+	if runs <= 0 {
+		runs = 100
+	}
+	if steps <= 0 {
+		steps = 100
+	}
+
+	for run := 0; run < runs; run++ {
+		seed := cfg.Seed + int64(run)
+		rnd := rand.New(rand.NewSource(seed))
+		ops := make([]Op, 0, steps)
+		for i := 0; i < steps; i++ {
+			ops = append(ops, Op{
+				Kind: OpKind(rnd.Intn(3)),
+				Val:  cfg.Gen(rnd),
+			})
+		}
+
+		if Replay(ops, cfg.Equal) == nil {
+			continue
+		}
+		ops = shrink(ops, func(ops []Op) bool {
+			return Replay(ops, cfg.Equal) != nil
+		})
+		return &Failure{
+			Seed: seed,
+			Ops:  ops,
+			Err:  Replay(ops, cfg.Equal),
+		}
+	}
+	return nil
+}
+
+// Test is a shortcut of Check for tests, which reports the failure via tb.
+func Test(tb testing.TB, cfg Config) {
+	tb.Helper()
+	if err := Check(cfg); err != nil {
+		tb.Fatal(err)
+	}
+}
+
+// shrink returns a minimal subsequence of ops which still fails, by removing
+// chunks of operations as long as the sequence keeps failing.
+func shrink(ops []Op, fails func([]Op) bool) []Op {
+	for chunk := len(ops) / 2; chunk > 0; chunk /= 2 {
+		for start := 0; start+chunk <= len(ops); {
+			candidate := make([]Op, 0, len(ops)-chunk)
+			candidate = append(candidate, ops[:start]...)
+			candidate = append(candidate, ops[start+chunk:]...)
+			if fails(candidate) {
+				ops = candidate
+			} else {
+				start += chunk
+			}
+		}
+	}
+	return ops
+}
@@ -0,0 +1,130 @@
+package avltest
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/sym01/algo/avl"
+)
+
+type interval struct {
+	min, max int
+}
+
+func (l interval) Compare(right avl.Range) int {
+	r := right.(interval)
+	if l.max < r.min {
+		return -1
+	}
+	if l.min > r.max {
+		return 1
+	}
+	return 0
+}
+
+func (l interval) Contains(right avl.Range) bool {
+	r := right.(interval)
+	return l.min <= r.min && l.max >= r.max
+}
+
+func (l interval) Union(right avl.Range) avl.Range {
+	r := right.(interval)
+	if r.min < l.min {
+		l.min = r.min
+	}
+	if r.max > l.max {
+		l.max = r.max
+	}
+	return l
+}
+
+func genInterval(rnd *rand.Rand) avl.Range {
+	min := rnd.Intn(200)
+	return interval{min, min + rnd.Intn(10)}
+}
+
+func TestCheck(t *testing.T) {
+	Test(t, Config{
+		Gen:   genInterval,
+		Runs:  200,
+		Steps: 300,
+	})
+}
+
+// badOrder is not a strict weak ordering: the distant values are compared in
+// the reversed order.
+type badOrder int
+
+func (l badOrder) Compare(right avl.Range) int {
+	r := right.(badOrder)
+	switch {
+	case l == r:
+		return 0
+	case (l < r) != (l-r > 50 || r-l > 50):
+		return -1
+	default:
+		return 1
+	}
+}
+func (l badOrder) Contains(right avl.Range) bool   { return l == right }
+func (l badOrder) Union(right avl.Range) avl.Range { return l }
+
+func TestCheck_failure(t *testing.T) {
+	err := Check(Config{
+		Gen: func(rnd *rand.Rand) avl.Range {
+			return badOrder(rnd.Intn(200))
+		},
+	})
+
+	f, ok := err.(*Failure)
+	if !ok || f.Err == nil || !strings.Contains(err.Error(), "minimal failing sequence") {
+		t.Fatalf("unexpected failure: %v", err)
+	}
+	if Replay(f.Ops, nil) == nil {
+		t.Fatalf("the minimal sequence doesn't fail: %v", err)
+	}
+	if len(f.Ops) > 5 {
+		t.Fatalf("the failing sequence is not shrunk: %v", err)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	ops := []Op{
+		{OpInsert, interval{10, 15}},
+		{OpInsert, interval{20, 25}},
+		{OpInsert, interval{30, 35}},
+		{OpInsert, interval{14, 31}}, // merges all
+		{OpSearch, interval{16, 29}},
+		{OpDelete, interval{12, 12}},
+		{OpSearch, interval{30, 30}},
+	}
+	if err := Replay(ops, nil); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := Replay([]Op{{OpKind(42), interval{}}}, nil); err == nil {
+		t.Fatal("expect an error for an unknown operation")
+	}
+}
+
+func Test_shrink(t *testing.T) {
+	ops := make([]Op, 100)
+	for i := range ops {
+		ops[i] = Op{OpInsert, interval{i, i}}
+	}
+
+	// fails if both 17 and 42 are inserted
+	fails := func(ops []Op) bool {
+		found := 0
+		for _, op := range ops {
+			if v := op.Val.(interval).min; v == 17 || v == 42 {
+				found++
+			}
+		}
+		return found == 2
+	}
+	ret := shrink(ops, fails)
+	if len(ret) != 2 || ret[0].Val.(interval).min != 17 || ret[1].Val.(interval).min != 42 {
+		t.Fatalf("unexpected result, got %v", ret)
+	}
+}
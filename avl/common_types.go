@@ -24,7 +24,7 @@ type IntTree Tree
 
 // Insert a new Range into the AVL tree.
 func (t *IntTree) Insert(val int) {
-	t.root = t.root.insert(intRange(val))
+	(*Tree)(t).Insert(intRange(val))
 }
 
 // Search returns true if the AVL tree contains the <val>.
@@ -32,6 +32,11 @@ func (t *IntTree) Search(val int) bool {
 	return t.root.search(intRange(val))
 }
 
+// Delete removes the <val> from the AVL tree, and returns false if not found.
+func (t *IntTree) Delete(val int) bool {
+	return (*Tree)(t).Delete(intRange(val))
+}
+
 type byteRange []byte
 
 func (i byteRange) Compare(right Range) int   { return bytes.Compare(i, right.(byteRange)) }
@@ -39,11 +44,17 @@ func (i byteRange) Contains(right Range) bool { return bytes.Equal(i, right.(byt
 func (i byteRange) Union(right Range) Range   { return i }
 
 // BytesTree is a high-performance AVL tree for []byte.
+// The tree keeps its own copies of the keys, so the caller is free to reuse
+// the buffers after Insert.
 type BytesTree Tree
 
 // Insert a new Range into the AVL tree.
 func (t *BytesTree) Insert(val []byte) {
-	t.root = t.root.insert(byteRange(val))
+	if t.root.search(byteRange(val)) {
+		// avoid copying for nothing
+		return
+	}
+	(*Tree)(t).Insert(byteRange(append([]byte(nil), val...)))
 }
 
 // Search returns true if the AVL tree contains the <val>.
@@ -51,15 +62,143 @@ func (t *BytesTree) Search(val []byte) bool {
 	return t.root.search(byteRange(val))
 }
 
+// Delete removes the <val> from the AVL tree, and returns false if not found.
+func (t *BytesTree) Delete(val []byte) bool {
+	return (*Tree)(t).Delete(byteRange(val))
+}
+
 // StringTree is a high-performance AVL tree for String.
 type StringTree Tree
 
 // Insert a new Range into the AVL tree.
 func (t *StringTree) Insert(val string) {
-	t.root = t.root.insert(byteRange(val))
+	(*Tree)(t).Insert(byteRange(val))
 }
 
 // Search returns true if the AVL tree contains the <val>.
 func (t *StringTree) Search(val string) bool {
 	return t.root.search(byteRange(val))
 }
+
+// Delete removes the <val> from the AVL tree, and returns false if not found.
+func (t *StringTree) Delete(val string) bool {
+	return (*Tree)(t).Delete(byteRange(val))
+}
+
+// compactKey is a key whose leading bytes may be shared with another key.
+// The shared part is never modified.
+type compactKey struct {
+	shared []byte
+	own    []byte
+}
+
+func (i *compactKey) len() int { return len(i.shared) + len(i.own) }
+
+func (i *compactKey) at(idx int) byte {
+	if idx < len(i.shared) {
+		return i.shared[idx]
+	}
+	return i.own[idx-len(i.shared)]
+}
+
+// commonPrefix returns the length of the common prefix of current key and b.
+func (i *compactKey) commonPrefix(b []byte) (n int) {
+	l := i.len()
+	for n < l && n < len(b) && i.at(n) == b[n] {
+		n++
+	}
+	return
+}
+
+func (i *compactKey) Compare(right Range) int {
+	r := right.(*compactKey)
+	// fast path for the common shared prefix
+	if len(i.shared) > 0 && len(i.shared) == len(r.shared) && &i.shared[0] == &r.shared[0] {
+		return bytes.Compare(i.own, r.own)
+	}
+
+	l, rl := i.len(), r.len()
+	for idx := 0; idx < l && idx < rl; idx++ {
+		if a, b := i.at(idx), r.at(idx); a != b {
+			if a < b {
+				return -1
+			}
+			return 1
+		}
+	}
+	switch {
+	case l < rl:
+		return -1
+	case l > rl:
+		return 1
+	default:
+		return 0
+	}
+}
+func (i *compactKey) Contains(right Range) bool { return i.Compare(right) == 0 }
+func (i *compactKey) Union(right Range) Range   { return i }
+
+// CompactBytesTree is an AVL tree for []byte with prefix-compressed storage,
+// which is designed for the keys sharing long prefixes, such as URLs and
+// paths.
+//
+// On Insert, the longest common prefix between the new key and its neighbors
+// in the tree is shared rather than copied, so only the remaining bytes of
+// the key are stored. Like BytesTree, the tree keeps its own copies of the
+// keys.
+type CompactBytesTree Tree
+
+// Insert a new Range into the AVL tree.
+func (t *CompactBytesTree) Insert(val []byte) {
+	key := &compactKey{own: val}
+
+	// find the neighbors of the new key
+	var pred, succ *avlNode
+	for n := t.root; n != nil; {
+		switch factor := n.val.Compare(key); {
+		case factor < 0: // n < z
+			pred, n = n, n.right
+		case factor > 0: // n > z
+			succ, n = n, n.left
+		default: // n == z
+			return
+		}
+	}
+
+	var shared []byte
+	for _, n := range []*avlNode{pred, succ} {
+		if n == nil {
+			continue
+		}
+		neighbor := n.val.(*compactKey)
+		prefix := neighbor.commonPrefix(val)
+		if prefix > len(neighbor.shared) && len(neighbor.shared) > 0 {
+			// only the shared part of the neighbor is continuous
+			prefix = len(neighbor.shared)
+		}
+		if prefix <= len(shared) {
+			continue
+		}
+		if len(neighbor.shared) > 0 {
+			shared = neighbor.shared[:prefix:prefix]
+		} else {
+			shared = neighbor.own[:prefix:prefix]
+		}
+	}
+
+	key.shared = shared
+	key.own = append([]byte(nil), val[len(shared):]...)
+	(*Tree)(t).Insert(key)
+}
+
+// Search returns true if the AVL tree contains the <val>.
+func (t *CompactBytesTree) Search(val []byte) bool {
+	return t.root.search(&compactKey{own: val})
+}
+
+// Delete removes the <val> from the AVL tree, and returns false if not found.
+// The storage of the deleted key may be kept alive by the other keys sharing
+// its prefix.
+func (t *CompactBytesTree) Delete(val []byte) bool {
+	return (*Tree)(t).Delete(&compactKey{own: val})
+}
@@ -0,0 +1,168 @@
+package avl
+
+// Cursor is a position in a Tree, which can move forward and backward in
+// order. A Cursor is invalid until it has been positioned by Seek, First or
+// Last, and becomes invalid again once it moves beyond either end.
+//
+// It's allowed to modify the tree while a cursor is in use. If the tree has
+// been modified since the cursor was positioned, the cursor re-seeks on the
+// last element it was positioned at: Next moves to the first element after
+// it, Prev moves to the last element before it, and Value returns the first
+// element at or after it.
+type Cursor struct {
+	tree *Tree
+	node *avlNode
+	ver  uint64
+
+	// last is the value the cursor was positioned at.
+	last Range
+}
+
+// Cursor returns a new, invalid cursor of the tree.
+func (t *Tree) Cursor() *Cursor {
+	return &Cursor{tree: t}
+}
+
+// Valid returns true if the cursor is positioned at an element.
+func (c *Cursor) Valid() bool {
+	return c.Value() != nil
+}
+
+// Value returns the element the cursor is positioned at, or nil if the
+// cursor is invalid.
+func (c *Cursor) Value() Range {
+	if c.last != nil && c.ver != c.tree.ver {
+		c.set(c.tree.root.lowerBound(c.last, false))
+	}
+	if c.node == nil {
+		return nil
+	}
+	return c.node.val
+}
+
+// Seek moves the cursor to the first element which is greater than or equal
+// to the <val>, and returns false if there is no such element.
+func (c *Cursor) Seek(val Range) bool {
+	return c.set(c.tree.root.lowerBound(val, false))
+}
+
+// First moves the cursor to the min element, and returns false if the tree is
+// empty.
+func (c *Cursor) First() bool {
+	return c.set(c.tree.root.leftmost())
+}
+
+// Last moves the cursor to the max element, and returns false if the tree is
+// empty.
+func (c *Cursor) Last() bool {
+	return c.set(c.tree.root.rightmost())
+}
+
+// Next moves the cursor to the next element, and returns false if there is no
+// more element. It's a no-op and returns false if the cursor is invalid.
+func (c *Cursor) Next() bool {
+	switch {
+	case c.last == nil:
+		return false
+	case c.ver != c.tree.ver:
+		return c.set(c.tree.root.lowerBound(c.last, true))
+	case c.node == nil:
+		return false
+	default:
+		return c.set(c.node.next())
+	}
+}
+
+// Prev moves the cursor to the previous element, and returns false if there
+// is no more element. It's a no-op and returns false if the cursor is
+// invalid.
+func (c *Cursor) Prev() bool {
+	switch {
+	case c.last == nil:
+		return false
+	case c.ver != c.tree.ver:
+		return c.set(c.tree.root.before(c.last))
+	case c.node == nil:
+		return false
+	default:
+		return c.set(c.node.prev())
+	}
+}
+
+func (c *Cursor) set(n *avlNode) bool {
+	c.node, c.ver = n, c.tree.ver
+	if n == nil {
+		// the cursor becomes invalid
+		c.last = nil
+		return false
+	}
+	c.last = n.val
+	return true
+}
+
+func (n *avlNode) leftmost() *avlNode {
+	if n == nil {
+		return nil
+	}
+	for n.left != nil {
+		n = n.left
+	}
+	return n
+}
+
+func (n *avlNode) rightmost() *avlNode {
+	if n == nil {
+		return nil
+	}
+	for n.right != nil {
+		n = n.right
+	}
+	return n
+}
+
+// next returns the in-order successor of current node.
+func (n *avlNode) next() *avlNode {
+	if n.right != nil {
+		return n.right.leftmost()
+	}
+	for n.parent != nil && n.parent.right == n {
+		n = n.parent
+	}
+	return n.parent
+}
+
+// prev returns the in-order predecessor of current node.
+func (n *avlNode) prev() *avlNode {
+	if n.left != nil {
+		return n.left.rightmost()
+	}
+	for n.parent != nil && n.parent.left == n {
+		n = n.parent
+	}
+	return n.parent
+}
+
+// lowerBound returns the first node greater than or equal to the <val>, or
+// greater than the <val> if strict.
+func (n *avlNode) lowerBound(val Range, strict bool) (ret *avlNode) {
+	for n != nil {
+		if factor := n.val.Compare(val); factor > 0 || (!strict && factor == 0) {
+			ret, n = n, n.left
+		} else {
+			n = n.right
+		}
+	}
+	return
+}
+
+// before returns the last node less than the <val>.
+func (n *avlNode) before(val Range) (ret *avlNode) {
+	for n != nil {
+		if n.val.Compare(val) < 0 {
+			ret, n = n, n.right
+		} else {
+			n = n.left
+		}
+	}
+	return
+}
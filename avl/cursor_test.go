@@ -0,0 +1,100 @@
+package avl_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/sym01/algo/avl"
+)
+
+func TestCursor(t *testing.T) {
+	tree := new(avl.Tree)
+	c := tree.Cursor()
+	if c.First() || c.Last() || c.Valid() || c.Next() || c.Prev() {
+		t.Fatal("unexpected result for an empty tree")
+	}
+
+	for i := 0; i < 10; i++ {
+		tree.Insert(&intRange{i * 10, i*10 + 5})
+	}
+
+	var ret []avl.Range
+	for ok := c.First(); ok; ok = c.Next() {
+		ret = append(ret, c.Value())
+	}
+	if len(ret) != 10 || fmt.Sprint(ret[0], ret[9]) != "&{0 5} &{90 95}" {
+		t.Fatalf("unexpected result for iterating forward, got %v", ret)
+	}
+
+	ret = ret[:0]
+	for ok := c.Last(); ok; ok = c.Prev() {
+		ret = append(ret, c.Value())
+	}
+	if len(ret) != 10 || fmt.Sprint(ret[0], ret[9]) != "&{90 95} &{0 5}" {
+		t.Fatalf("unexpected result for iterating backward, got %v", ret)
+	}
+
+	testcases := []struct {
+		seek     int
+		expected string
+	}{
+		{-1, "&{0 5}"},
+		{23, "&{20 25}"}, // overlapped
+		{27, "&{30 35}"},
+		{100, "<nil>"},
+	}
+	for _, testcase := range testcases {
+		c.Seek(&intRange{testcase.seek, testcase.seek})
+		if ret := fmt.Sprint(c.Value()); ret != testcase.expected {
+			t.Errorf("unexpected result for seeking %d, expect %s, got %s",
+				testcase.seek, testcase.expected, ret)
+		}
+	}
+}
+
+func TestCursor_Mutation(t *testing.T) {
+	tree := new(avl.Tree)
+	for i := 0; i < 100; i++ {
+		tree.Insert(&intRange{i, i})
+	}
+
+	// delete every element while scanning
+	c := tree.Cursor()
+	cnt := 0
+	for ok := c.First(); ok; ok = c.Next() {
+		tree.Delete(c.Value())
+		cnt++
+	}
+	if cnt != 100 || tree.Cursor().First() {
+		t.Fatalf("unexpected result for deleting while scanning, %d elements visited", cnt)
+	}
+
+	// insert while scanning backward
+	for i := 0; i < 100; i += 2 {
+		tree.Insert(&intRange{i, i})
+	}
+	var ret []int
+	for ok := c.Last(); ok; ok = c.Prev() {
+		v := c.Value().(*intRange).min
+		ret = append(ret, v)
+		if v > 0 {
+			tree.Insert(&intRange{v - 1, v - 1})
+		}
+	}
+	if len(ret) != 99 || ret[0] != 98 || ret[len(ret)-1] != 0 {
+		t.Fatalf("unexpected result for inserting while scanning, got %v", ret)
+	}
+
+	// the element at cursor is deleted
+	c.Seek(&intRange{50, 50})
+	tree.Delete(&intRange{50, 50})
+	tree.Delete(&intRange{51, 51})
+	if v := c.Value(); fmt.Sprint(v) != "&{52 52}" {
+		t.Fatalf("unexpected value after deleting, got %v", v)
+	}
+	c.Seek(&intRange{60, 60})
+	tree.Delete(&intRange{60, 60})
+	if !c.Prev() || fmt.Sprint(c.Value()) != "&{59 59}" {
+		t.Fatalf("unexpected value after deleting, got %v", c.Value())
+	}
+}
@@ -123,6 +123,17 @@ func (l *intRange) Union(right avl.Range) avl.Range {
 	return ret
 }
 
+func (l *intRange) Subtract(right avl.Range) (ret []avl.Range) {
+	r := right.(*intRange)
+	if l.min < r.min {
+		ret = append(ret, &intRange{l.min, r.min - 1})
+	}
+	if l.max > r.max {
+		ret = append(ret, &intRange{r.max + 1, l.max})
+	}
+	return
+}
+
 func ExampleTree() {
 	data := []*intRange{
 		{10, 15},
@@ -145,3 +156,24 @@ func ExampleTree() {
 	// true
 	// false
 }
+
+func ExampleTree_RemoveRange() {
+	tree := new(avl.Tree)
+	tree.Insert(&intRange{10, 100})
+	tree.Insert(&intRange{110, 120})
+	tree.Insert(&intRange{130, 140})
+
+	tree.RemoveRange(&intRange{40, 60})
+	tree.RemoveRange(&intRange{115, 135})
+
+	c := tree.Cursor()
+	for ok := c.First(); ok; ok = c.Next() {
+		fmt.Println(c.Value())
+	}
+
+	// Output:
+	// &{10 39}
+	// &{61 100}
+	// &{110 114}
+	// &{136 140}
+}
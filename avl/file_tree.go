@@ -0,0 +1,638 @@
+package avl
+
+import (
+	"bytes"
+	"container/list"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+const (
+	fileTreeMagic = "AVLTREE1"
+
+	// magic, page size, max key size, root, free list, pages, count
+	fileTreeHeaderSize = 8 + 4 + 4 + 8 + 8 + 8 + 8
+
+	// left, right, height, key length
+	filePageHeaderSize = 8 + 8 + 4 + 2
+
+	defaultMaxKeySize = 64
+	defaultCachePages = 1024
+)
+
+// ErrKeyTooLarge is returned by FileTree.Insert if the key is longer than
+// the MaxKeySize of the tree.
+var ErrKeyTooLarge = errors.New("avl: key too large")
+
+// FileTreeOptions contains the options for OpenFileTree.
+type FileTreeOptions struct {
+	// MaxKeySize is the max length of the keys, which determines the size of
+	// the pages. It's ignored when opening an existing file.
+	// 64 will be used if MaxKeySize <= 0.
+	MaxKeySize int
+
+	// CachePages is the number of pages kept in memory.
+	// 1024 will be used if CachePages <= 0.
+	CachePages int
+}
+
+// FileTree is a disk-backed AVL tree for []byte, for the datasets which can't
+// fit in memory. Every node is stored in a fixed-size page of a local file,
+// and only a small number of pages are cached in memory.
+//
+// The updates are crash-consistent by shadow paging. The pages of the last
+// synced tree are never modified in place: a page is copied to a new page on
+// its first modification after Sync, and the replaced pages are not reused
+// until the next Sync. Sync writes the new pages first, and then switches to
+// the new tree by rewriting the header at once. So after a crash at any time,
+// OpenFileTree sees the tree of the last successful Sync or Close, and only
+// the modifications after that are lost.
+//
+// Any I/O error is sticky: once an operation fails, all the following
+// operations will return the same error.
+//
+// It's not thread-safe, even for read ops.
+type FileTree struct {
+	f          *os.File
+	pageSize   int
+	maxKeySize int
+
+	root  uint64 // page id of the root, 0 for an empty tree
+	pages uint64 // number of pages in the file, including the header
+	count uint64 // number of keys
+	dirty bool   // whether the tree was modified after the last Sync
+
+	// free are the pages which can be reused right now. pending are the pages
+	// still used by the last synced tree, including the pages of its free
+	// list, which become free after the next Sync. fresh are the pages
+	// allocated after the last Sync, which can be modified in place.
+	free    []uint64
+	pending []uint64
+	fresh   map[uint64]struct{}
+
+	cache      map[uint64]*filePage
+	lru        *list.List
+	cachePages int
+	buf        []byte
+
+	err error
+}
+
+type filePage struct {
+	id          uint64
+	left, right uint64
+	h           int32 // height of the subtree, 1 for a leaf
+	key         []byte
+
+	dirty bool
+	elem  *list.Element
+}
+
+// OpenFileTree opens the tree stored in the file, creating it if necessary.
+// opts can be nil for default options.
+func OpenFileTree(path string, opts *FileTreeOptions) (*FileTree, error) {
+	if opts == nil {
+		opts = new(FileTreeOptions)
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	t := &FileTree{
+		f:          f,
+		maxKeySize: opts.MaxKeySize,
+		fresh:      make(map[uint64]struct{}),
+		cache:      make(map[uint64]*filePage),
+		lru:        list.New(),
+		cachePages: opts.CachePages,
+	}
+	if t.maxKeySize <= 0 {
+		t.maxKeySize = defaultMaxKeySize
+	}
+	if t.cachePages <= 0 {
+		t.cachePages = defaultCachePages
+	}
+
+	if err := t.load(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return t, nil
+}
+
+// load reads the header of the file, or initializes an empty file.
+func (t *FileTree) load() error {
+	header := make([]byte, fileTreeHeaderSize)
+	n, err := t.f.ReadAt(header, 0)
+	if n == 0 && err == io.EOF {
+		// a new file
+		if t.maxKeySize > 0xffff {
+			return ErrKeyTooLarge
+		}
+		t.pageSize = filePageHeaderSize + t.maxKeySize
+		if t.pageSize < fileTreeHeaderSize {
+			t.pageSize = fileTreeHeaderSize
+		}
+		t.pages, t.dirty = 1, true
+		t.buf = make([]byte, t.pageSize)
+		return t.Sync()
+	}
+	if err != nil {
+		return err
+	}
+
+	if string(header[:8]) != fileTreeMagic {
+		return fmt.Errorf("avl: %s is not a FileTree file", t.f.Name())
+	}
+	t.pageSize = int(binary.BigEndian.Uint32(header[8:]))
+	t.maxKeySize = int(binary.BigEndian.Uint32(header[12:]))
+	t.root = binary.BigEndian.Uint64(header[16:])
+	list := binary.BigEndian.Uint64(header[24:])
+	t.pages = binary.BigEndian.Uint64(header[32:])
+	t.count = binary.BigEndian.Uint64(header[40:])
+	if t.pageSize < filePageHeaderSize+t.maxKeySize || t.pageSize < fileTreeHeaderSize {
+		return fmt.Errorf("avl: %s has an invalid header", t.f.Name())
+	}
+	t.buf = make([]byte, t.pageSize)
+	return t.loadFreeList(list)
+}
+
+// loadFreeList reads the free list stored in the chain of pages starting from
+// the id. The free list is kept in the key area of the pages, 8 bytes per
+// page id, and the pages are chained by the left. The pages of the chain are
+// pending, as they are still used by the last synced tree.
+func (t *FileTree) loadFreeList(id uint64) error {
+	for ; id != 0; id = binary.BigEndian.Uint64(t.buf) {
+		if id >= t.pages || uint64(len(t.pending)) >= t.pages {
+			return fmt.Errorf("avl: invalid free list in %s", t.f.Name())
+		}
+		if _, err := t.f.ReadAt(t.buf, int64(id)*int64(t.pageSize)); err != nil {
+			return err
+		}
+		t.pending = append(t.pending, id)
+
+		n := int(binary.BigEndian.Uint16(t.buf[20:]))
+		if filePageHeaderSize+n > t.pageSize {
+			return fmt.Errorf("avl: invalid free list in %s", t.f.Name())
+		}
+		for i := filePageHeaderSize; i+8 <= filePageHeaderSize+n; i += 8 {
+			t.free = append(t.free, binary.BigEndian.Uint64(t.buf[i:]))
+		}
+	}
+	return nil
+}
+
+// fail records the first I/O error.
+func (t *FileTree) fail(err error) {
+	if t.err == nil {
+		t.err = err
+	}
+}
+
+// page returns the page with the id, reading it from the file if necessary.
+// It never evicts any page, so the returned page stays valid until the next
+// call of shrink.
+func (t *FileTree) page(id uint64) *filePage {
+	if p, ok := t.cache[id]; ok {
+		t.lru.MoveToFront(p.elem)
+		return p
+	}
+
+	p := &filePage{id: id}
+	if id == 0 || id >= t.pages {
+		t.fail(fmt.Errorf("avl: invalid page %d in %s", id, t.f.Name()))
+		return p
+	}
+	if _, err := t.f.ReadAt(t.buf, int64(id)*int64(t.pageSize)); err != nil {
+		t.fail(err)
+		return p
+	}
+	p.left = binary.BigEndian.Uint64(t.buf)
+	p.right = binary.BigEndian.Uint64(t.buf[8:])
+	p.h = int32(binary.BigEndian.Uint32(t.buf[16:]))
+	keyLen := int(binary.BigEndian.Uint16(t.buf[20:]))
+	if keyLen > t.maxKeySize {
+		t.fail(fmt.Errorf("avl: corrupted page %d in %s", id, t.f.Name()))
+		return p
+	}
+	p.key = append([]byte(nil), t.buf[filePageHeaderSize:filePageHeaderSize+keyLen]...)
+
+	t.cache[id] = p
+	p.elem = t.lru.PushFront(p)
+	return p
+}
+
+func (t *FileTree) write(p *filePage) {
+	binary.BigEndian.PutUint64(t.buf, p.left)
+	binary.BigEndian.PutUint64(t.buf[8:], p.right)
+	binary.BigEndian.PutUint32(t.buf[16:], uint32(p.h))
+	binary.BigEndian.PutUint16(t.buf[20:], uint16(len(p.key)))
+	n := copy(t.buf[filePageHeaderSize:], p.key)
+	for i := filePageHeaderSize + n; i < len(t.buf); i++ {
+		t.buf[i] = 0
+	}
+
+	if _, err := t.f.WriteAt(t.buf, int64(p.id)*int64(t.pageSize)); err != nil {
+		t.fail(err)
+		return
+	}
+	p.dirty = false
+}
+
+// shrink evicts the least recently used pages until the cache fits.
+func (t *FileTree) shrink() {
+	for t.lru.Len() > t.cachePages && t.err == nil {
+		p := t.lru.Remove(t.lru.Back()).(*filePage)
+		if p.dirty {
+			t.write(p)
+		}
+		delete(t.cache, p.id)
+	}
+}
+
+// alloc returns a new dirty page, reusing the free pages if possible.
+func (t *FileTree) alloc() *filePage {
+	p := &filePage{id: t.pages, dirty: true}
+	if n := len(t.free); n != 0 {
+		p.id, t.free = t.free[n-1], t.free[:n-1]
+	} else {
+		t.pages++
+	}
+
+	t.fresh[p.id] = struct{}{}
+	t.cache[p.id] = p
+	p.elem = t.lru.PushFront(p)
+	return p
+}
+
+// release frees the page. A fresh page can be reused right now, while a page
+// of the last synced tree becomes pending.
+func (t *FileTree) release(p *filePage) {
+	if _, ok := t.fresh[p.id]; ok {
+		delete(t.fresh, p.id)
+		t.free = append(t.free, p.id)
+	} else {
+		t.pending = append(t.pending, p.id)
+	}
+	if p.elem != nil {
+		t.lru.Remove(p.elem)
+		delete(t.cache, p.id)
+	}
+}
+
+// mut returns the page to be modified instead of p, which is p itself if it's
+// fresh. Otherwise, p is a page of the last synced tree, which is copied to
+// a new page and then released. Either way, the returned page is dirty.
+func (t *FileTree) mut(p *filePage) *filePage {
+	if _, ok := t.fresh[p.id]; ok {
+		p.dirty = true
+		return p
+	}
+
+	c := t.alloc()
+	c.left, c.right, c.h, c.key = p.left, p.right, p.h, p.key
+	t.release(p)
+	return c
+}
+
+func (t *FileTree) height(id uint64) int32 {
+	if id == 0 {
+		return 0
+	}
+	return t.page(id).h
+}
+
+func (t *FileTree) updateHeight(p *filePage) *filePage {
+	h := t.height(p.left)
+	if rh := t.height(p.right); rh > h {
+		h = rh
+	}
+	if h+1 != p.h {
+		p = t.mut(p)
+		p.h = h + 1
+	}
+	return p
+}
+
+func (t *FileTree) rotateLeft(p *filePage) (z *filePage) {
+	p = t.mut(p)
+	z = t.mut(t.page(p.right))
+	p.right, z.left = z.left, p.id
+	t.updateHeight(p)
+	t.updateHeight(z)
+	return
+}
+
+func (t *FileTree) rotateRight(p *filePage) (z *filePage) {
+	p = t.mut(p)
+	z = t.mut(t.page(p.left))
+	p.left, z.right = z.right, p.id
+	t.updateHeight(p)
+	t.updateHeight(z)
+	return
+}
+
+// balance restores the AVL property of the subtree p, and returns its new
+// root.
+func (t *FileTree) balance(p *filePage) *filePage {
+	p = t.updateHeight(p)
+	switch factor := t.height(p.left) - t.height(p.right); {
+	case factor > 1: // left heavy
+		if l := t.page(p.left); t.height(l.left) < t.height(l.right) {
+			p = t.setLeft(p, t.rotateLeft(l).id)
+		}
+		return t.rotateRight(p)
+	case factor < -1: // right heavy
+		if r := t.page(p.right); t.height(r.right) < t.height(r.left) {
+			p = t.setRight(p, t.rotateRight(r).id)
+		}
+		return t.rotateLeft(p)
+	}
+	return p
+}
+
+// setLeft and setRight set a child of the page p, and return the page to be
+// used instead of p, see mut .
+func (t *FileTree) setLeft(p *filePage, id uint64) *filePage {
+	if p.left != id {
+		p = t.mut(p)
+		p.left = id
+	}
+	return p
+}
+
+func (t *FileTree) setRight(p *filePage, id uint64) *filePage {
+	if p.right != id {
+		p = t.mut(p)
+		p.right = id
+	}
+	return p
+}
+
+// insert the key into the subtree, and returns the new root of the subtree.
+func (t *FileTree) insert(id uint64, key []byte) uint64 {
+	if id == 0 {
+		p := t.alloc()
+		p.key, p.h = append([]byte(nil), key...), 1
+		t.count++
+		return p.id
+	}
+
+	p := t.page(id)
+	switch factor := bytes.Compare(p.key, key); {
+	case factor < 0: // p < key
+		p = t.setRight(p, t.insert(p.right, key))
+	case factor > 0: // p > key
+		p = t.setLeft(p, t.insert(p.left, key))
+	default: // p == key
+		return id
+	}
+	return t.balance(p).id
+}
+
+// delete the key from the subtree, and returns the new root of the subtree.
+func (t *FileTree) delete(id uint64, key []byte) (uint64, bool) {
+	if id == 0 {
+		return 0, false
+	}
+
+	p := t.page(id)
+	var found bool
+	switch factor := bytes.Compare(p.key, key); {
+	case factor < 0: // p < key
+		var right uint64
+		if right, found = t.delete(p.right, key); found {
+			p = t.setRight(p, right)
+		}
+	case factor > 0: // p > key
+		var left uint64
+		if left, found = t.delete(p.left, key); found {
+			p = t.setLeft(p, left)
+		}
+	default: // p == key
+		found = true
+		t.count--
+		if p.left == 0 || p.right == 0 {
+			child := p.left | p.right
+			t.release(p)
+			return child, true
+		}
+
+		// replace the key with its in-order successor
+		right, succ := t.deleteMin(p.right)
+		p = t.mut(p)
+		p.key = succ
+		p = t.setRight(p, right)
+	}
+	if !found {
+		return id, false
+	}
+	return t.balance(p).id, true
+}
+
+// deleteMin deletes the min key from the subtree, and returns the new root of
+// the subtree and the min key.
+func (t *FileTree) deleteMin(id uint64) (uint64, []byte) {
+	p := t.page(id)
+	if p.left == 0 {
+		right, key := p.right, p.key
+		t.release(p)
+		return right, key
+	}
+
+	left, key := t.deleteMin(p.left)
+	p = t.setLeft(p, left)
+	return t.balance(p).id, key
+}
+
+// Insert a new key into the tree.
+func (t *FileTree) Insert(key []byte) error {
+	if t.err != nil {
+		return t.err
+	}
+	if len(key) > t.maxKeySize {
+		return ErrKeyTooLarge
+	}
+
+	t.dirty = true
+	t.root = t.insert(t.root, key)
+	t.shrink()
+	return t.err
+}
+
+// Search returns true if the tree contains the key.
+func (t *FileTree) Search(key []byte) (bool, error) {
+	if t.err != nil {
+		return false, t.err
+	}
+
+	found := false
+	for id := t.root; id != 0 && t.err == nil; {
+		p := t.page(id)
+		switch factor := bytes.Compare(p.key, key); {
+		case factor < 0: // p < key
+			id = p.right
+		case factor > 0: // p > key
+			id = p.left
+		default: // p == key
+			found, id = true, 0
+		}
+	}
+	t.shrink()
+	return found && t.err == nil, t.err
+}
+
+// Delete removes the key from the tree, and returns false if not found.
+func (t *FileTree) Delete(key []byte) (bool, error) {
+	if t.err != nil {
+		return false, t.err
+	}
+
+	t.dirty = true
+	root, found := t.delete(t.root, key)
+	t.root = root
+	t.shrink()
+	return found && t.err == nil, t.err
+}
+
+// Ascend calls fn for every key greater than or equal to from in ascending
+// order, until fn returns false. A nil from means starting from the min key.
+// The key passed to fn must not be modified, and the tree must not be
+// modified during the iteration.
+func (t *FileTree) Ascend(from []byte, fn func(key []byte) bool) error {
+	if t.err != nil {
+		return t.err
+	}
+
+	// the stack keeps page ids rather than pages, since the pages may be
+	// evicted during the iteration.
+	var stack []uint64
+	for id := t.root; id != 0 && t.err == nil; {
+		p := t.page(id)
+		if from == nil || bytes.Compare(p.key, from) >= 0 {
+			stack = append(stack, id)
+			id = p.left
+		} else {
+			id = p.right
+		}
+	}
+
+	for len(stack) > 0 && t.err == nil {
+		p := t.page(stack[len(stack)-1])
+		stack = stack[:len(stack)-1]
+		if !fn(p.key) {
+			break
+		}
+		for id := p.right; id != 0 && t.err == nil; {
+			stack = append(stack, id)
+			id = t.page(id).left
+		}
+		t.shrink()
+	}
+	t.shrink()
+	return t.err
+}
+
+// Len returns the number of keys in the tree.
+func (t *FileTree) Len() uint64 {
+	return t.count
+}
+
+// Sync writes all the modified pages and the header into the file, and
+// commits the file to the stable storage. The header is written after all the
+// pages have been persisted, which switches the file to the current tree at
+// once.
+func (t *FileTree) Sync() error {
+	if t.err != nil || !t.dirty {
+		return t.err
+	}
+
+	// The pending pages become free after this Sync, along with the free
+	// pages, while the pages of the new free list become pending.
+	per := (t.pageSize - filePageHeaderSize) / 8
+	var list []*filePage
+	for len(list)*per < len(t.free)+len(t.pending) {
+		list = append(list, t.alloc())
+	}
+	free := append(append([]uint64(nil), t.free...), t.pending...)
+	for i, p := range list {
+		n := len(free) - i*per
+		if n > per {
+			n = per
+		}
+		p.key = make([]byte, 8*n)
+		for j := range free[i*per : i*per+n] {
+			binary.BigEndian.PutUint64(p.key[8*j:], free[i*per+j])
+		}
+		if i+1 < len(list) {
+			p.left = list[i+1].id
+		}
+	}
+
+	for e := t.lru.Front(); e != nil && t.err == nil; e = e.Next() {
+		if p := e.Value.(*filePage); p.dirty {
+			t.write(p)
+		}
+	}
+	if t.err != nil {
+		return t.err
+	}
+
+	// the pages must be persisted before the header switches to them
+	if err := t.f.Sync(); err != nil {
+		t.fail(err)
+		return err
+	}
+	head := uint64(0)
+	if len(list) != 0 {
+		head = list[0].id
+	}
+	if err := t.writeHeader(head); err != nil {
+		return err
+	}
+
+	t.free, t.pending = free, t.pending[:0]
+	for _, p := range list {
+		t.pending = append(t.pending, p.id)
+		t.lru.Remove(p.elem)
+		delete(t.cache, p.id)
+	}
+	t.fresh = make(map[uint64]struct{})
+	t.dirty = false
+	return nil
+}
+
+// writeHeader writes the header with the head of the free list into the file,
+// and commits the file to the stable storage.
+func (t *FileTree) writeHeader(list uint64) error {
+	header := t.buf[:fileTreeHeaderSize]
+	copy(header, fileTreeMagic)
+	binary.BigEndian.PutUint32(header[8:], uint32(t.pageSize))
+	binary.BigEndian.PutUint32(header[12:], uint32(t.maxKeySize))
+	binary.BigEndian.PutUint64(header[16:], t.root)
+	binary.BigEndian.PutUint64(header[24:], list)
+	binary.BigEndian.PutUint64(header[32:], t.pages)
+	binary.BigEndian.PutUint64(header[40:], t.count)
+	if _, err := t.f.WriteAt(header, 0); err != nil {
+		t.fail(err)
+		return err
+	}
+	if err := t.f.Sync(); err != nil {
+		t.fail(err)
+		return err
+	}
+	return nil
+}
+
+// Close syncs and closes the underlying file.
+func (t *FileTree) Close() error {
+	err := t.Sync()
+	if cerr := t.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
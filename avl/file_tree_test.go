@@ -0,0 +1,200 @@
+package avl_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sym01/algo/avl"
+)
+
+// tempDir returns a new temporary directory, which is removed when the test
+// finishes.
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "avl")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+func TestFileTree(t *testing.T) {
+	path := filepath.Join(tempDir(t), "tree.db")
+	opts := &avl.FileTreeOptions{MaxKeySize: 16, CachePages: 8}
+	tree, err := avl.OpenFileTree(path, opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	rnd := rand.New(rand.NewSource(1))
+	perm := rnd.Perm(2000)
+	for _, i := range perm {
+		if err := tree.Insert([]byte(fmt.Sprintf("key-%05d", i))); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	for _, i := range perm[:1000] {
+		if found, err := tree.Delete([]byte(fmt.Sprintf("key-%05d", i))); !found || err != nil {
+			t.Fatalf("unexpected result for deleting %d, got %v, %v", i, found, err)
+		}
+	}
+	// reuse the freed pages
+	for _, i := range perm[:500] {
+		if err := tree.Insert([]byte(fmt.Sprintf("key-%05d", i))); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	if err := tree.Insert(bytes.Repeat([]byte("a"), 17)); err != avl.ErrKeyTooLarge {
+		t.Fatalf("unexpected error for a large key: %v", err)
+	}
+	if err := tree.Close(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// reopen
+	tree, err = avl.OpenFileTree(path, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer tree.Close()
+
+	expected := make(map[string]bool)
+	for _, i := range append(perm[:500:500], perm[1000:]...) {
+		expected[fmt.Sprintf("key-%05d", i)] = true
+	}
+	for _, i := range perm {
+		key := fmt.Sprintf("key-%05d", i)
+		if found, err := tree.Search([]byte(key)); found != expected[key] || err != nil {
+			t.Fatalf("unexpected result for %s, expect %v, got %v, %v", key, expected[key], found, err)
+		}
+	}
+	if tree.Len() != uint64(len(expected)) {
+		t.Fatalf("unexpected length, expect %d, got %d", len(expected), tree.Len())
+	}
+
+	var prev []byte
+	cnt := 0
+	err = tree.Ascend([]byte("key-01000"), func(key []byte) bool {
+		if bytes.Compare(key, []byte("key-01000")) < 0 || (prev != nil && bytes.Compare(prev, key) >= 0) {
+			t.Fatalf("unexpected order, got %s after %s", key, prev)
+		}
+		prev = append(prev[:0], key...)
+		cnt++
+		return true
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	for key := range expected {
+		if key >= "key-01000" {
+			cnt--
+		}
+	}
+	if cnt != 0 {
+		t.Fatalf("unexpected number of keys in the scan, diff %d", cnt)
+	}
+}
+
+func TestOpenFileTree(t *testing.T) {
+	dir := tempDir(t)
+	if _, err := avl.OpenFileTree(filepath.Join(dir, "not-exist", "tree.db"), nil); err == nil {
+		t.Fatal("expect an error for an invalid path")
+	}
+
+	path := filepath.Join(dir, "invalid.db")
+	if err := ioutil.WriteFile(path, bytes.Repeat([]byte("invalid"), 100), 0o644); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := avl.OpenFileTree(path, nil); err == nil {
+		t.Fatal("expect an error for an invalid file")
+	}
+}
+
+func TestFileTree_crash(t *testing.T) {
+	dir := tempDir(t)
+	path := filepath.Join(dir, "tree.db")
+	tree, err := avl.OpenFileTree(path, &avl.FileTreeOptions{MaxKeySize: 16, CachePages: 4})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer tree.Close()
+
+	// snapshot simulates a crash, by copying the file while the tree is open
+	snapshot := func(name string) string {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		copied := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(copied, data, 0o644); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		return copied
+	}
+
+	// check opens the copied file, and checks if it has exactly the keys of
+	// the synced set. The recovered tree must be usable as well.
+	check := func(copied string, synced map[string]bool) {
+		recovered, err := avl.OpenFileTree(copied, nil)
+		if err != nil {
+			t.Fatalf("unexpected error for %s: %s", copied, err)
+		}
+		defer recovered.Close()
+
+		var keys []string
+		if err := recovered.Ascend(nil, func(key []byte) bool {
+			keys = append(keys, string(key))
+			return true
+		}); err != nil {
+			t.Fatalf("unexpected error for %s: %s", copied, err)
+		}
+		for i, key := range keys {
+			if !synced[key] || (i > 0 && keys[i-1] >= key) {
+				t.Fatalf("unexpected key %s in %s", key, copied)
+			}
+		}
+		if len(keys) != len(synced) || recovered.Len() != uint64(len(synced)) {
+			t.Fatalf("unexpected length of %s, expect %d, got %d keys, Len %d",
+				copied, len(synced), len(keys), recovered.Len())
+		}
+		if err := recovered.Insert([]byte("new")); err != nil {
+			t.Fatalf("unexpected error for inserting into %s: %s", copied, err)
+		}
+	}
+
+	rnd := rand.New(rand.NewSource(1))
+	synced := make(map[string]bool)
+	current := make(map[string]bool)
+	for round := 0; round < 10; round++ {
+		// modify enough pages to evict some of them before Sync
+		for i := 0; i < 100; i++ {
+			key := fmt.Sprintf("key-%03d", rnd.Intn(300))
+			if current[key] {
+				if found, err := tree.Delete([]byte(key)); !found || err != nil {
+					t.Fatalf("unexpected result for deleting %s, got %v, %v", key, found, err)
+				}
+				delete(current, key)
+			} else {
+				if err := tree.Insert([]byte(key)); err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				current[key] = true
+			}
+		}
+		check(snapshot(fmt.Sprintf("modified-%d.db", round)), synced)
+
+		if err := tree.Sync(); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		synced = make(map[string]bool)
+		for key := range current {
+			synced[key] = true
+		}
+		check(snapshot(fmt.Sprintf("synced-%d.db", round)), synced)
+	}
+}
@@ -11,6 +11,16 @@ import (
 type ITree[T any] interface {
 	Insert(T)
 	Search(T) bool
+	Delete(T) bool
+
+	// Len returns the number of elements in the tree.
+	Len() int
+
+	// TopK returns the k largest elements in descending order.
+	TopK(k int) []T
+
+	// BottomK returns the k smallest elements in ascending order.
+	BottomK(k int) []T
 }
 
 // NewOrderedTree creates a new high-performance AVL tree instance for
@@ -19,6 +29,17 @@ func NewOrderedTree[T constraints.Ordered]() ITree[T] {
 	return new(orderedTree[T])
 }
 
+// NewBoundedOrderedTree creates a new AVL tree instance for ordered types,
+// which keeps only the k largest elements. Once the tree is full, inserting a
+// new element evicts the min element, and inserting an element less than the
+// min element is a no-op. It panics if k <= 0.
+func NewBoundedOrderedTree[T constraints.Ordered](k int) ITree[T] {
+	if k <= 0 {
+		panic("avl: non-positive capacity for the bounded tree")
+	}
+	return &boundedTree[T]{capacity: k}
+}
+
 type orderedRange[T constraints.Ordered] struct {
 	v T
 }
@@ -49,3 +70,34 @@ func (i *orderedTree[T]) Insert(v T) {
 func (i *orderedTree[T]) Search(v T) bool {
 	return i.Tree.Search(orderedRange[T]{v})
 }
+func (i *orderedTree[T]) Delete(v T) bool {
+	return i.Tree.Delete(orderedRange[T]{v})
+}
+func (i *orderedTree[T]) TopK(k int) (ret []T) {
+	for n := i.root.rightmost(); n != nil && len(ret) < k; n = n.prev() {
+		ret = append(ret, n.val.(orderedRange[T]).v)
+	}
+	return
+}
+func (i *orderedTree[T]) BottomK(k int) (ret []T) {
+	for n := i.root.leftmost(); n != nil && len(ret) < k; n = n.next() {
+		ret = append(ret, n.val.(orderedRange[T]).v)
+	}
+	return
+}
+
+type boundedTree[T constraints.Ordered] struct {
+	orderedTree[T]
+	capacity int
+}
+
+func (i *boundedTree[T]) Insert(v T) {
+	if i.Len() >= i.capacity {
+		min := i.root.leftmost()
+		if v <= min.val.(orderedRange[T]).v || i.Search(v) {
+			return
+		}
+		i.Tree.Delete(min.val)
+	}
+	i.orderedTree.Insert(v)
+}
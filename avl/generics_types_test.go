@@ -5,6 +5,8 @@ package avl_test
 
 import (
 	"fmt"
+	"math/rand"
+	"testing"
 
 	"github.com/sym01/algo/avl"
 )
@@ -81,3 +83,44 @@ func ExampleNewOrderedTree_string() {
 	// Output:
 	// abccc not in tree
 }
+
+func TestOrderedTree_TopK(t *testing.T) {
+	tree := avl.NewOrderedTree[int]()
+	if ret := tree.TopK(3); ret != nil {
+		t.Fatalf("unexpected result for an empty tree, got %v", ret)
+	}
+
+	for _, i := range rand.New(rand.NewSource(1)).Perm(100) {
+		tree.Insert(i)
+	}
+	if ret := fmt.Sprint(tree.TopK(3)); ret != "[99 98 97]" {
+		t.Fatalf("unexpected result for TopK, got %s", ret)
+	}
+	if ret := fmt.Sprint(tree.BottomK(3)); ret != "[0 1 2]" {
+		t.Fatalf("unexpected result for BottomK, got %s", ret)
+	}
+	if ret := tree.TopK(1000); len(ret) != 100 || tree.Len() != 100 {
+		t.Fatalf("unexpected result for TopK, got %d elements", len(ret))
+	}
+}
+
+func TestNewBoundedOrderedTree(t *testing.T) {
+	tree := avl.NewBoundedOrderedTree[int](5)
+	for _, i := range rand.New(rand.NewSource(1)).Perm(100) {
+		tree.Insert(i)
+		tree.Insert(i) // duplicated
+	}
+	if ret := fmt.Sprint(tree.BottomK(10)); ret != "[95 96 97 98 99]" || tree.Len() != 5 {
+		t.Fatalf("unexpected result for the bounded tree, got %s", ret)
+	}
+	if tree.Search(94) || !tree.Search(95) {
+		t.Fatal("unexpected result for searching the bounded tree")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expect a panic for non-positive capacity")
+		}
+	}()
+	avl.NewBoundedOrderedTree[int](0)
+}
@@ -0,0 +1,227 @@
+package avl
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"math/bits"
+)
+
+// MerkleTree is an AVL tree which maintains a hash for every subtree, so that
+// two trees can be compared and the differences can be located cheaply.
+//
+// The hash of a subtree is the sum, modulo 2^256, of the SHA-256 hashes of its
+// elements. Since the sum doesn't depend on the shape of the tree, two trees
+// with the same elements always have the same RootHash, no matter in which
+// order the elements had been inserted. It's good for detecting accidental
+// differences, but it's not designed to resist deliberately crafted
+// collisions.
+//
+// The elements are expected to be disjoint, which means the Range.Union will
+// never be called in most cases.
+type MerkleTree struct {
+	tree   Tree
+	encode func(Range) []byte
+}
+
+// NewMerkleTree creates a new MerkleTree. The encode must return the
+// canonical binary representation of an element, which is used for hashing.
+func NewMerkleTree(encode func(Range) []byte) *MerkleTree {
+	m := &MerkleTree{encode: encode}
+	m.tree.aug = m.augment
+	return m
+}
+
+// digest is a 256-bit unsigned integer, in little-endian limbs.
+type digest [4]uint64
+
+func (d digest) add(r digest) (ret digest) {
+	var carry uint64
+	for i := range d {
+		ret[i], carry = bits.Add64(d[i], r[i], carry)
+	}
+	return
+}
+
+func (d digest) sub(r digest) (ret digest) {
+	var borrow uint64
+	for i := range d {
+		ret[i], borrow = bits.Sub64(d[i], r[i], borrow)
+	}
+	return
+}
+
+type merkleItem struct {
+	val Range
+
+	hashed bool
+	h      digest // the hash of val
+	sum    digest // the hash of the subtree
+}
+
+func (l *merkleItem) Compare(right Range) int {
+	return l.val.Compare(right.(*merkleItem).val)
+}
+
+func (l *merkleItem) Contains(right Range) bool {
+	return l.val.Contains(right.(*merkleItem).val)
+}
+
+func (l *merkleItem) Union(right Range) Range {
+	return &merkleItem{val: l.val.Union(right.(*merkleItem).val)}
+}
+
+func itemOf(n *avlNode) *merkleItem {
+	return n.val.(*merkleItem)
+}
+
+func sumOf(n *avlNode) digest {
+	if n == nil {
+		return digest{}
+	}
+	return itemOf(n).sum
+}
+
+func (m *MerkleTree) augment(n *avlNode) {
+	item := itemOf(n)
+	if !item.hashed {
+		sum := sha256.Sum256(m.encode(item.val))
+		for i := range item.h {
+			item.h[i] = binary.BigEndian.Uint64(sum[len(sum)-8*(i+1):])
+		}
+		item.hashed = true
+	}
+	item.sum = sumOf(n.left).add(item.h).add(sumOf(n.right))
+}
+
+// Insert a new Range into the tree.
+func (m *MerkleTree) Insert(val Range) {
+	m.tree.Insert(&merkleItem{val: val})
+}
+
+// Search returns true if the tree contains the <val>.
+func (m *MerkleTree) Search(val Range) bool {
+	return m.tree.Search(&merkleItem{val: val})
+}
+
+// Delete removes the element which contains the <val>, and returns false if
+// there is no such element.
+func (m *MerkleTree) Delete(val Range) bool {
+	return m.tree.Delete(&merkleItem{val: val})
+}
+
+// RootHash returns the hash of the whole tree, in big-endian.
+func (m *MerkleTree) RootHash() (ret [sha256.Size]byte) {
+	sum := sumOf(m.tree.root)
+	for i := range sum {
+		binary.BigEndian.PutUint64(ret[len(ret)-8*(i+1):], sum[i])
+	}
+	return
+}
+
+// Diff compares the tree with the other, and returns the elements which only
+// exist in the other as added, and the elements which only exist in the
+// current tree as removed. Two elements are considered as the same if they
+// are equal according to Range.Compare and have the same encoding.
+//
+// Diff only descends into the subtrees whose hashes differ from the hashes
+// of the same key ranges in the other tree, so it's cheap if the trees are
+// almost the same.
+func (m *MerkleTree) Diff(other *MerkleTree) (added, removed []Range) {
+	var walk func(n *avlNode, lo, hi Range)
+	walk = func(n *avlNode, lo, hi Range) {
+		if n == nil {
+			added = other.tree.root.appendBetween(added, lo, hi)
+			return
+		}
+		if sumOf(n) == other.tree.root.sumBetween(lo, hi) {
+			return
+		}
+
+		item := itemOf(n)
+		walk(n.left, lo, item)
+
+		// the item may overlap multiple elements of the other, and each of
+		// them is reported at the first element of current tree it overlaps
+		prev := n.prev()
+		found := false
+		other.tree.root.overlapping(item, func(val Range) bool {
+			o := val.(*merkleItem)
+			switch {
+			case o.h == item.h:
+				found = true
+			case prev == nil || prev.val.Compare(o) != 0:
+				added = append(added, o.val)
+			}
+			return true
+		})
+		if !found {
+			removed = append(removed, item.val)
+		}
+		walk(n.right, item, hi)
+	}
+	walk(m.tree.root, nil, nil)
+	return
+}
+
+// sumBetween returns the hash of the elements between lo and hi exclusively.
+// A nil bound means unbounded.
+func (n *avlNode) sumBetween(lo, hi Range) digest {
+	ret := sumOf(n)
+	if lo != nil {
+		ret = ret.sub(n.sumBefore(lo, true))
+	}
+	if hi != nil {
+		ret = ret.sub(n.sumAfter(hi, true))
+	}
+	return ret
+}
+
+// sumBefore returns the hash of the elements less than val, or less than or
+// equal to val if inclusive.
+func (n *avlNode) sumBefore(val Range, inclusive bool) (ret digest) {
+	for n != nil {
+		factor := n.val.Compare(val)
+		if factor < 0 || (inclusive && factor == 0) {
+			ret = ret.add(sumOf(n.left)).add(itemOf(n).h)
+			n = n.right
+		} else {
+			n = n.left
+		}
+	}
+	return
+}
+
+// sumAfter returns the hash of the elements greater than val, or greater than
+// or equal to val if inclusive.
+func (n *avlNode) sumAfter(val Range, inclusive bool) (ret digest) {
+	for n != nil {
+		factor := n.val.Compare(val)
+		if factor > 0 || (inclusive && factor == 0) {
+			ret = ret.add(sumOf(n.right)).add(itemOf(n).h)
+			n = n.left
+		} else {
+			n = n.right
+		}
+	}
+	return
+}
+
+// appendBetween appends the values of the elements between lo and hi
+// exclusively to ret in order. A nil bound means unbounded.
+func (n *avlNode) appendBetween(ret []Range, lo, hi Range) []Range {
+	if n == nil {
+		return ret
+	}
+	afterLo := lo == nil || n.val.Compare(lo) > 0
+	beforeHi := hi == nil || n.val.Compare(hi) < 0
+	if afterLo {
+		ret = n.left.appendBetween(ret, lo, hi)
+	}
+	if afterLo && beforeHi {
+		ret = append(ret, itemOf(n).val)
+	}
+	if beforeHi {
+		ret = n.right.appendBetween(ret, lo, hi)
+	}
+	return ret
+}
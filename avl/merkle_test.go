@@ -0,0 +1,125 @@
+package avl_test
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/sym01/algo/avl"
+)
+
+func encodeIntRange(r avl.Range) []byte {
+	return []byte(fmt.Sprint(r))
+}
+
+func sortedRanges(rs []avl.Range) string {
+	ret := make([]string, 0, len(rs))
+	for _, r := range rs {
+		ret = append(ret, fmt.Sprint(r))
+	}
+	sort.Strings(ret)
+	return fmt.Sprint(ret)
+}
+
+func TestMerkleTree_RootHash(t *testing.T) {
+	l := avl.NewMerkleTree(encodeIntRange)
+	r := avl.NewMerkleTree(encodeIntRange)
+	if l.RootHash() != r.RootHash() {
+		t.Fatal("unexpected root hash for empty trees")
+	}
+
+	rnd := rand.New(rand.NewSource(1))
+	for _, i := range rnd.Perm(1000) {
+		l.Insert(&intRange{i * 10, i*10 + 5})
+	}
+	for _, i := range rnd.Perm(1000) {
+		r.Insert(&intRange{i * 10, i*10 + 5})
+	}
+	if l.RootHash() != r.RootHash() {
+		t.Fatal("unexpected root hash for trees with the same elements")
+	}
+
+	r.Delete(&intRange{500, 500})
+	if l.RootHash() == r.RootHash() {
+		t.Fatal("unexpected root hash for trees with different elements")
+	}
+	r.Insert(&intRange{500, 505})
+	if l.RootHash() != r.RootHash() {
+		t.Fatal("unexpected root hash after restoring the element")
+	}
+}
+
+func TestMerkleTree_Diff(t *testing.T) {
+	l := avl.NewMerkleTree(encodeIntRange)
+	r := avl.NewMerkleTree(encodeIntRange)
+	for i := 0; i < 1000; i++ {
+		l.Insert(&intRange{i * 10, i*10 + 5})
+		r.Insert(&intRange{i * 10, i*10 + 5})
+	}
+	if added, removed := l.Diff(r); added != nil || removed != nil {
+		t.Fatalf("unexpected diff for the same trees: %v, %v", added, removed)
+	}
+
+	r.Delete(&intRange{100, 100})
+	r.Delete(&intRange{9990, 9990})
+	r.Insert(&intRange{10006, 10008})
+	r.Insert(&intRange{-5, -1})
+	r.Delete(&intRange{5000, 5000})
+	r.Insert(&intRange{5000, 5002}) // same key, different value
+	l.Delete(&intRange{7000, 7000})
+
+	added, removed := l.Diff(r)
+	expectedAdded := []avl.Range{
+		&intRange{-5, -1}, &intRange{5000, 5002}, &intRange{7000, 7005}, &intRange{10006, 10008},
+	}
+	expectedRemoved := []avl.Range{
+		&intRange{100, 105}, &intRange{5000, 5005}, &intRange{9990, 9995},
+	}
+	if sortedRanges(added) != sortedRanges(expectedAdded) {
+		t.Errorf("unexpected added, expect %v, got %v", sortedRanges(expectedAdded), sortedRanges(added))
+	}
+	if sortedRanges(removed) != sortedRanges(expectedRemoved) {
+		t.Errorf("unexpected removed, expect %v, got %v", sortedRanges(expectedRemoved), sortedRanges(removed))
+	}
+}
+
+func TestMerkleTree_Diff_overlapping(t *testing.T) {
+	newTree := func(rs ...*intRange) *avl.MerkleTree {
+		m := avl.NewMerkleTree(encodeIntRange)
+		for _, r := range rs {
+			m.Insert(r)
+		}
+		return m
+	}
+	testcases := []struct {
+		l, r           *avl.MerkleTree
+		added, removed string
+	}{
+		{
+			newTree(&intRange{0, 10}),
+			newTree(&intRange{0, 3}, &intRange{5, 10}),
+			"[&{0 3} &{5 10}]", "[&{0 10}]",
+		},
+		{
+			newTree(&intRange{0, 3}, &intRange{5, 10}),
+			newTree(&intRange{0, 10}),
+			"[&{0 10}]", "[&{0 3} &{5 10}]",
+		},
+		{
+			newTree(&intRange{0, 3}, &intRange{5, 10}, &intRange{20, 25}),
+			newTree(&intRange{0, 3}, &intRange{6, 8}, &intRange{9, 22}),
+			"[&{6 8} &{9 22}]", "[&{20 25} &{5 10}]",
+		},
+	}
+
+	for _, testcase := range testcases {
+		added, removed := testcase.l.Diff(testcase.r)
+		if ret := sortedRanges(added); ret != testcase.added {
+			t.Errorf("unexpected added, expect %s, got %s", testcase.added, ret)
+		}
+		if ret := sortedRanges(removed); ret != testcase.removed {
+			t.Errorf("unexpected removed, expect %s, got %s", testcase.removed, ret)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package avl
+
+import (
+	"errors"
+	"time"
+)
+
+// TimeRange is a half-open time window [Start, End), which implements Range.
+// A TimeRange with the same Start and End stands for the instant Start.
+type TimeRange struct {
+	Start time.Time
+	End   time.Time
+}
+
+// isInstant returns true if current element stands for an instant.
+func (l TimeRange) isInstant() bool {
+	return l.Start.Equal(l.End)
+}
+
+// before returns true if current element ends before the right starts.
+func (l TimeRange) before(r TimeRange) bool {
+	if l.isInstant() {
+		return l.Start.Before(r.Start)
+	}
+	return !l.End.After(r.Start)
+}
+
+// Compare implements Range. Two TimeRanges are equal if they overlap.
+func (l TimeRange) Compare(right Range) int {
+	r := right.(TimeRange)
+	if l.before(r) {
+		return -1
+	}
+	if r.before(l) {
+		return 1
+	}
+	return 0
+}
+
+// Contains implements Range. An instant is contained if it's within the time
+// window, or it's the same instant.
+func (l TimeRange) Contains(right Range) bool {
+	r := right.(TimeRange)
+	if l.Start.After(r.Start) {
+		return false
+	}
+	if r.isInstant() {
+		return r.Start.Before(l.End) || (l.isInstant() && r.Start.Equal(l.Start))
+	}
+	return !r.End.After(l.End)
+}
+
+// Union implements Range.
+func (l TimeRange) Union(right Range) Range {
+	r := right.(TimeRange)
+	if r.Start.Before(l.Start) {
+		l.Start = r.Start
+	}
+	if r.End.After(l.End) {
+		l.End = r.End
+	}
+	return l
+}
+
+// Duration returns the length of the time window.
+func (l TimeRange) Duration() time.Duration {
+	return l.End.Sub(l.Start)
+}
+
+var (
+	// ErrConflict is returned by Schedule.Book if the time window overlaps an
+	// existing booking.
+	ErrConflict = errors.New("avl: time range conflicts with an existing booking")
+
+	// ErrInvalidTimeRange is returned by Schedule.Book if the End of the time
+	// window is not after its Start.
+	ErrInvalidTimeRange = errors.New("avl: invalid time range")
+)
+
+// Schedule is a set of non-overlapping bookings, based on the AVL tree of
+// TimeRange.
+type Schedule struct {
+	tree Tree
+}
+
+// Book adds the time window into the schedule. It returns ErrConflict if the
+// window overlaps any existing booking, and the schedule is left untouched.
+func (s *Schedule) Book(r TimeRange) error {
+	if !r.End.After(r.Start) {
+		return ErrInvalidTimeRange
+	}
+	if s.tree.root.find(r) != nil {
+		return ErrConflict
+	}
+
+	s.tree.Insert(r)
+	return nil
+}
+
+// Cancel removes the booking which contains the <r>, and returns false if
+// there is no such booking.
+func (s *Schedule) Cancel(r TimeRange) bool {
+	return s.tree.Delete(r)
+}
+
+// Conflicts returns all the bookings overlapping the window, in order.
+func (s *Schedule) Conflicts(window TimeRange) (ret []TimeRange) {
+	s.tree.root.overlapping(window, func(val Range) bool {
+		ret = append(ret, val.(TimeRange))
+		return true
+	})
+	return
+}
+
+// FreeSlots returns all the free time windows within <between>, which last
+// at least minDuration, in order.
+func (s *Schedule) FreeSlots(between TimeRange, minDuration time.Duration) (ret []TimeRange) {
+	cur := between.Start
+	appendSlot := func(end time.Time) {
+		if slot := (TimeRange{cur, end}); slot.Duration() > 0 && slot.Duration() >= minDuration {
+			ret = append(ret, slot)
+		}
+	}
+
+	for _, booked := range s.Conflicts(between) {
+		appendSlot(booked.Start)
+		if booked.End.After(cur) {
+			cur = booked.End
+		}
+	}
+	appendSlot(between.End)
+	return
+}
@@ -0,0 +1,142 @@
+package avl_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/sym01/algo/avl"
+)
+
+var day = time.Date(2022, 4, 1, 0, 0, 0, 0, time.UTC)
+
+func hours(from, to float64) avl.TimeRange {
+	return avl.TimeRange{
+		Start: day.Add(time.Duration(from * float64(time.Hour))),
+		End:   day.Add(time.Duration(to * float64(time.Hour))),
+	}
+}
+
+func formatTimeRanges(rs []avl.TimeRange) string {
+	ret := ""
+	for _, r := range rs {
+		ret += fmt.Sprintf("[%s,%s)", r.Start.Format("15:04"), r.End.Format("15:04"))
+	}
+	return ret
+}
+
+func TestSchedule_Book(t *testing.T) {
+	s := new(avl.Schedule)
+	testcases := []struct {
+		window avl.TimeRange
+		err    error
+	}{
+		{hours(9, 10), nil},
+		{hours(10, 11), nil}, // half-open, no conflict
+		{hours(8, 9), nil},
+		{hours(9.5, 9.75), avl.ErrConflict},
+		{hours(7, 12), avl.ErrConflict},
+		{hours(13, 13), avl.ErrInvalidTimeRange},
+		{hours(14, 13), avl.ErrInvalidTimeRange},
+	}
+	for _, testcase := range testcases {
+		if err := s.Book(testcase.window); err != testcase.err {
+			t.Errorf("unexpected error for %s, expect %v, got %v",
+				formatTimeRanges([]avl.TimeRange{testcase.window}), testcase.err, err)
+		}
+	}
+
+	if !s.Cancel(hours(9.5, 9.5)) {
+		t.Fatal("unexpected result for canceling an existing booking")
+	}
+	if err := s.Book(hours(9.5, 9.75)); err != nil {
+		t.Fatalf("unexpected error after canceling: %v", err)
+	}
+}
+
+func TestSchedule_Conflicts(t *testing.T) {
+	s := new(avl.Schedule)
+	for _, w := range []avl.TimeRange{hours(9, 10), hours(11, 12), hours(13, 14), hours(15, 16)} {
+		if err := s.Book(w); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	testcases := []struct {
+		window   avl.TimeRange
+		expected string
+	}{
+		{hours(10, 11), ""},
+		{hours(9.5, 13.5), "[09:00,10:00)[11:00,12:00)[13:00,14:00)"},
+		{hours(12, 12), ""},
+		{hours(11, 11), "[11:00,12:00)"}, // instant
+		{hours(0, 24), "[09:00,10:00)[11:00,12:00)[13:00,14:00)[15:00,16:00)"},
+	}
+	for _, testcase := range testcases {
+		if ret := formatTimeRanges(s.Conflicts(testcase.window)); ret != testcase.expected {
+			t.Errorf("unexpected conflicts for %s, expect %s, got %s",
+				formatTimeRanges([]avl.TimeRange{testcase.window}), testcase.expected, ret)
+		}
+	}
+}
+
+func TestSchedule_FreeSlots(t *testing.T) {
+	s := new(avl.Schedule)
+	for _, w := range []avl.TimeRange{hours(9, 10), hours(10.5, 12), hours(13, 14)} {
+		if err := s.Book(w); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	ret := formatTimeRanges(s.FreeSlots(hours(8, 18), time.Hour))
+	if expected := "[08:00,09:00)[12:00,13:00)[14:00,18:00)"; ret != expected {
+		t.Fatalf("unexpected free slots, expect %s, got %s", expected, ret)
+	}
+	ret = formatTimeRanges(s.FreeSlots(hours(9.5, 13.5), 0))
+	if expected := "[10:00,10:30)[12:00,13:00)"; ret != expected {
+		t.Fatalf("unexpected free slots, expect %s, got %s", expected, ret)
+	}
+}
+
+func TestTimeRange_Contains(t *testing.T) {
+	testcases := []struct {
+		l, r     avl.TimeRange
+		expected bool
+	}{
+		{hours(9, 10), hours(9, 10), true},
+		{hours(9, 10), hours(9.5, 9.5), true},
+		{hours(9, 10), hours(9, 9), true},
+		{hours(9, 10), hours(10, 10), false}, // half-open
+		{hours(9, 9), hours(9, 9), true},
+		{hours(9, 9), hours(10, 10), false},
+		{hours(9, 9), hours(9, 10), false},
+	}
+
+	for _, item := range testcases {
+		if ret := item.l.Contains(item.r); ret != item.expected {
+			t.Errorf("unexpected result for %s contains %s, expect %v, got %v",
+				formatTimeRanges([]avl.TimeRange{item.l}), formatTimeRanges([]avl.TimeRange{item.r}),
+				item.expected, ret)
+		}
+	}
+}
+
+func TestTimeRange_instant(t *testing.T) {
+	tree := new(avl.Tree)
+	unions := 0
+	tree.SetHooks(avl.Hooks{
+		OnUnion: func(old, new avl.Range) { unions++ },
+	})
+
+	tree.Insert(hours(9, 9))
+	tree.Insert(hours(9, 9))
+	if unions != 0 || tree.Len() != 1 {
+		t.Fatalf("unexpected result for inserting an instant twice, got %d unions and %d elements", unions, tree.Len())
+	}
+	if !tree.Search(hours(9, 9)) {
+		t.Fatal("unexpected result for searching an instant")
+	}
+	if !tree.Delete(hours(9, 9)) || tree.Len() != 0 {
+		t.Fatal("unexpected result for deleting an instant")
+	}
+}
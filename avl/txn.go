@@ -0,0 +1,297 @@
+package avl
+
+import "errors"
+
+var (
+	// ErrTxnConflict is returned by Txn.Commit if the tree had been modified
+	// after the transaction began.
+	ErrTxnConflict = errors.New("avl: tree modified since the transaction began")
+
+	// ErrTxnDone is returned by Txn.Commit if the transaction had already
+	// been committed or rolled back.
+	ErrTxnDone = errors.New("avl: transaction already committed or rolled back")
+)
+
+// Txn is a batch of modifications to a Tree, which become visible together
+// on Commit, or not at all.
+//
+// The transaction works by path copying: a write copies only the nodes on its
+// path from the root, and shares all the other nodes with the original tree,
+// so it costs O(log n) regardless of the size of the tree. The copied nodes
+// don't keep pointers to their parents until Commit, which links them into
+// the tree and swaps the root. The original tree stays untouched before that.
+//
+// A Txn is not thread-safe, and Commit must be serialized with any other
+// writes to the underlying tree.
+type Txn struct {
+	tree *Tree
+	ver  uint64
+
+	// root and size describe the version of the tree seen by the transaction.
+	root *avlNode
+	size int
+
+	// owned are the nodes copied by the transaction, which can be modified
+	// in place. The other nodes are shared with the tree.
+	owned  map[*avlNode]struct{}
+	events []txnEvent
+	done   bool
+}
+
+const (
+	opInsert = iota
+	opUnion
+	opDelete
+)
+
+type txnEvent struct {
+	op       int
+	old, new Range
+}
+
+// Begin starts a new transaction on the tree.
+func (t *Tree) Begin() *Txn {
+	return &Txn{
+		tree: t,
+		ver:  t.ver,
+		root: t.root,
+		size: t.size,
+	}
+}
+
+// Insert a new Range within the transaction, just like Tree.Insert .
+// It panics if the transaction had been committed or rolled back.
+func (x *Txn) Insert(val Range) {
+	x.check()
+	n := x.root.find(val)
+	switch {
+	case n == nil:
+		x.root = x.insert(x.root, val)
+		x.size++
+		x.record(opInsert, nil, val)
+	case n.val.Contains(val): // nothing changed
+	default:
+		old := n.val
+		x.root = x.delete(x.root, old)
+
+		// absorb the neighbors overlapping the merged element, the same as
+		// Tree.absorb . The remaining elements never overlap the old, so it
+		// still locates the neighbors.
+		merged := old.Union(val)
+		for {
+			m := x.root.before(old)
+			if m == nil || m.val.Compare(merged) != 0 {
+				if m = x.root.lowerBound(old, true); m == nil || m.val.Compare(merged) != 0 {
+					break
+				}
+			}
+			merged = m.val.Union(merged)
+			x.root = x.delete(x.root, m.val)
+			x.size--
+			x.record(opDelete, m.val, nil)
+		}
+
+		x.root = x.insert(x.root, merged)
+		x.record(opUnion, old, merged)
+	}
+}
+
+// Delete removes the element which contains the <val> within the transaction,
+// and returns false if there is no such element.
+// It panics if the transaction had been committed or rolled back.
+func (x *Txn) Delete(val Range) bool {
+	x.check()
+	n := x.root.find(val)
+	if n == nil || !n.val.Contains(val) {
+		return false
+	}
+
+	x.root = x.delete(x.root, n.val)
+	x.size--
+	x.record(opDelete, n.val, nil)
+	return true
+}
+
+// Search returns true if the tree contains the <val>, taking the pending
+// modifications of the transaction into account.
+func (x *Txn) Search(val Range) bool {
+	return x.root.search(val)
+}
+
+// Commit makes all the modifications of the transaction visible at once, and
+// invokes the hooks of the tree for each of them in order.
+// It fails with ErrTxnConflict if the tree had been modified after Begin, in
+// which case the tree is left untouched.
+func (x *Txn) Commit() error {
+	if x.done {
+		return ErrTxnDone
+	}
+	if x.tree.ver != x.ver {
+		return ErrTxnConflict
+	}
+	x.done = true
+	if len(x.events) == 0 {
+		return nil
+	}
+
+	x.link(x.root, nil)
+	x.tree.root, x.tree.size = x.root, x.size
+	x.tree.ver++
+	h := &x.tree.hooks
+	for _, e := range x.events {
+		f := h.OnInsert
+		switch e.op {
+		case opUnion:
+			f = h.OnUnion
+		case opDelete:
+			f = h.OnDelete
+		}
+		if f != nil {
+			f(e.old, e.new)
+		}
+	}
+	x.root, x.owned, x.events = nil, nil, nil
+	return nil
+}
+
+// Rollback discards all the modifications of the transaction.
+func (x *Txn) Rollback() {
+	x.done = true
+	x.root, x.owned, x.events = nil, nil, nil
+}
+
+func (x *Txn) check() {
+	if x.done {
+		panic("avl: use of a finished transaction")
+	}
+	if x.owned == nil {
+		x.owned = make(map[*avlNode]struct{})
+	}
+}
+
+func (x *Txn) record(op int, old, new Range) {
+	x.events = append(x.events, txnEvent{op, old, new})
+}
+
+// link sets the parent pointers of the subtree n, which are only missing for
+// the owned nodes and their children. The parents of the shared nodes are
+// overwritten as well, which doesn't matter to the tree being replaced.
+func (x *Txn) link(n, parent *avlNode) {
+	if n == nil {
+		return
+	}
+	n.parent = parent
+	if _, ok := x.owned[n]; ok {
+		x.link(n.left, n)
+		x.link(n.right, n)
+	}
+}
+
+// own returns a copy of the node n which can be modified in place, that is,
+// n itself if it's owned already.
+func (x *Txn) own(n *avlNode) *avlNode {
+	if _, ok := x.owned[n]; ok {
+		return n
+	}
+
+	c := &avlNode{
+		val:   n.val,
+		left:  n.left,
+		right: n.right,
+		h:     n.h,
+	}
+	x.owned[c] = struct{}{}
+	return c
+}
+
+// insert adds the <val>, which overlaps no element, into the subtree n, and
+// returns the new root of the subtree.
+func (x *Txn) insert(n *avlNode, val Range) *avlNode {
+	if n == nil {
+		n = &avlNode{val: val}
+		x.owned[n] = struct{}{}
+		return n
+	}
+
+	n = x.own(n)
+	if n.val.Compare(val) < 0 {
+		n.right = x.insert(n.right, val)
+	} else {
+		n.left = x.insert(n.left, val)
+	}
+	return x.balance(n)
+}
+
+// delete removes the element <val> from the subtree n, and returns the new
+// root of the subtree. The <val> must be in the subtree.
+func (x *Txn) delete(n *avlNode, val Range) *avlNode {
+	factor := n.val.Compare(val)
+	switch {
+	case factor == 0 && n.left == nil:
+		return n.right
+	case factor == 0 && n.right == nil:
+		return n.left
+	}
+
+	n = x.own(n)
+	switch {
+	case factor < 0:
+		n.right = x.delete(n.right, val)
+	case factor > 0:
+		n.left = x.delete(n.left, val)
+	default:
+		// replace current element with the in-order successor
+		n.right, n.val = x.deleteMin(n.right)
+	}
+	return x.balance(n)
+}
+
+// deleteMin removes the first element from the subtree n, and returns the new
+// root of the subtree and the removed element.
+func (x *Txn) deleteMin(n *avlNode) (*avlNode, Range) {
+	if n.left == nil {
+		return n.right, n.val
+	}
+
+	n = x.own(n)
+	var min Range
+	n.left, min = x.deleteMin(n.left)
+	return x.balance(n), min
+}
+
+// balance restores the AVL property for the owned node n, whose subtrees are
+// balanced already, and returns the new root of the subtree.
+func (x *Txn) balance(n *avlNode) *avlNode {
+	switch factor := n.left.height() - n.right.height(); {
+	case factor > 1: // left heavy
+		if n.left.left.height() < n.left.right.height() {
+			n.left = x.rotateLeft(x.own(n.left))
+		}
+		return x.rotateRight(n)
+	case factor < -1: // right heavy
+		if n.right.right.height() < n.right.left.height() {
+			n.right = x.rotateRight(x.own(n.right))
+		}
+		return x.rotateLeft(n)
+	}
+	n.updateHeight()
+	return n
+}
+
+// rotateLeft and rotateRight are the same as the ones of avlNode, except that
+// the parent pointers are left alone.
+func (x *Txn) rotateLeft(n *avlNode) *avlNode {
+	z := x.own(n.right)
+	n.right, z.left = z.left, n
+	n.updateHeight()
+	z.updateHeight()
+	return z
+}
+
+func (x *Txn) rotateRight(n *avlNode) *avlNode {
+	z := x.own(n.left)
+	n.left, z.right = z.right, n
+	n.updateHeight()
+	z.updateHeight()
+	return z
+}
@@ -0,0 +1,195 @@
+package avl_test
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/sym01/algo/avl"
+)
+
+func TestTxn(t *testing.T) {
+	tree := new(avl.Tree)
+	tree.Insert(&intRange{10, 15})
+	tree.Insert(&intRange{20, 25})
+
+	var inserted, deleted int
+	tree.SetHooks(avl.Hooks{
+		OnInsert: func(old, new avl.Range) { inserted++ },
+		OnDelete: func(old, new avl.Range) { deleted++ },
+	})
+
+	txn := tree.Begin()
+	txn.Insert(&intRange{30, 35})
+	if !txn.Delete(&intRange{10, 10}) {
+		t.Fatal("unexpected result for deleting within the txn")
+	}
+	if txn.Delete(&intRange{40, 40}) {
+		t.Fatal("unexpected result for deleting a missing element")
+	}
+
+	if !txn.Search(&intRange{30, 30}) || txn.Search(&intRange{10, 10}) {
+		t.Fatal("the txn doesn't see its own writes")
+	}
+	if tree.Search(&intRange{30, 30}) || !tree.Search(&intRange{10, 10}) {
+		t.Fatal("the tree is modified before commit")
+	}
+	if inserted != 0 || deleted != 0 {
+		t.Fatal("hooks are invoked before commit")
+	}
+
+	if err := txn.Commit(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !tree.Search(&intRange{30, 30}) || tree.Search(&intRange{10, 10}) {
+		t.Fatal("the tree is not modified after commit")
+	}
+	if inserted != 1 || deleted != 1 {
+		t.Fatalf("unexpected hook calls, got %d inserts and %d deletes", inserted, deleted)
+	}
+	if err := txn.Commit(); err != avl.ErrTxnDone {
+		t.Fatalf("unexpected error for committing twice: %v", err)
+	}
+}
+
+func TestTxn_Rollback(t *testing.T) {
+	tree := new(avl.Tree)
+	tree.Insert(&intRange{10, 15})
+
+	txn := tree.Begin()
+	txn.Insert(&intRange{20, 25})
+	txn.Rollback()
+	if tree.Search(&intRange{20, 20}) {
+		t.Fatal("the tree is modified after rollback")
+	}
+	if err := txn.Commit(); err != avl.ErrTxnDone {
+		t.Fatalf("unexpected error for committing after rollback: %v", err)
+	}
+}
+
+func TestTxn_Conflict(t *testing.T) {
+	tree := new(avl.Tree)
+	txn := tree.Begin()
+	txn.Insert(&intRange{20, 25})
+	tree.Insert(&intRange{10, 15})
+
+	if err := txn.Commit(); err != avl.ErrTxnConflict {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if tree.Search(&intRange{20, 20}) {
+		t.Fatal("the conflicting txn is committed")
+	}
+}
+
+func TestTxn_Union(t *testing.T) {
+	tree := new(avl.Tree)
+	var events []string
+	tree.SetHooks(avl.Hooks{
+		OnInsert: func(old, new avl.Range) { events = append(events, "insert") },
+		OnUnion:  func(old, new avl.Range) { events = append(events, "union") },
+		OnDelete: func(old, new avl.Range) { events = append(events, "delete") },
+	})
+
+	txn := tree.Begin()
+	for _, val := range []*intRange{
+		{10, 15}, {20, 25}, {30, 35},
+		{14, 21}, // merged with a neighbor absorbed
+		{11, 12}, // contained, nothing changed
+		{24, 31},
+	} {
+		txn.Insert(val)
+	}
+	if err := txn.Commit(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := []string{"insert", "insert", "insert", "delete", "union", "delete", "union"}
+	if fmt.Sprint(events) != fmt.Sprint(expected) {
+		t.Fatalf("unexpected hook calls, expect %v, got %v", expected, events)
+	}
+	if tree.Len() != 1 || !tree.Search(&intRange{10, 35}) {
+		t.Fatalf("unexpected tree after commit, got %v", avl.DebugPreorder(tree))
+	}
+}
+
+func TestTxn_random(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	tree, expected := new(avl.Tree), new(avl.Tree)
+	for i := 0; i < 200; i++ {
+		tree.Insert(&intRange{i * 10, i*10 + 5})
+		expected.Insert(&intRange{i * 10, i*10 + 5})
+	}
+
+	for round := 0; round < 20; round++ {
+		txn := tree.Begin()
+		for i := 0; i < 50; i++ {
+			min := rnd.Intn(2100)
+			val := &intRange{min, min + rnd.Intn(15)}
+			if rnd.Intn(2) == 0 {
+				txn.Insert(val)
+				expected.Insert(val)
+			} else if txn.Delete(val) != expected.Delete(val) {
+				t.Fatalf("unexpected result for deleting %v", val)
+			}
+		}
+		if err := txn.Commit(); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		// walk in both directions, which relies on the parent pointers
+		var got, want []string
+		c, e := tree.Cursor(), expected.Cursor()
+		for ok := c.Last(); ok; ok = c.Prev() {
+			got = append([]string{fmt.Sprint(c.Value())}, got...)
+		}
+		for ok := e.First(); ok; ok = e.Next() {
+			want = append(want, fmt.Sprint(e.Value()))
+		}
+		if fmt.Sprint(got) != fmt.Sprint(want) || tree.Len() != expected.Len() {
+			t.Fatalf("unexpected tree after round %d, expect %v, got %v", round, want, got)
+		}
+	}
+}
+
+func TestTxn_pathCopying(t *testing.T) {
+	tree := new(avl.Tree)
+	for i := 0; i < 1<<14; i++ {
+		tree.Insert(&intRange{i * 10, i*10 + 5})
+	}
+
+	val := &intRange{1000, 1001}
+	allocs := testing.AllocsPerRun(100, func() {
+		txn := tree.Begin()
+		txn.Delete(val)
+		txn.Rollback()
+	})
+	if allocs > 100 {
+		t.Fatalf("the txn copies too much of the tree, got %v allocs", allocs)
+	}
+}
+
+func TestTxn_Done(t *testing.T) {
+	tree := new(avl.Tree)
+	tree.Insert(&intRange{10, 15})
+
+	txn := tree.Begin()
+	txn.Rollback()
+	testcases := map[string]func(){
+		"Insert":         func() { txn.Insert(&intRange{20, 25}) },
+		"Delete":         func() { txn.Delete(&intRange{10, 10}) },
+		"Delete/missing": func() { txn.Delete(&intRange{40, 40}) },
+	}
+	for name, fn := range testcases {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("unexpected result for %s on a finished txn, expect a panic", name)
+				}
+			}()
+			fn()
+		}()
+	}
+	if !tree.Search(&intRange{10, 10}) {
+		t.Fatal("the tree is modified by a finished txn")
+	}
+}
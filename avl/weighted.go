@@ -0,0 +1,149 @@
+package avl
+
+import (
+	"math"
+	"math/rand"
+)
+
+// WeightedTree is an AVL tree whose elements carry weights, and the sum of the
+// weights is maintained for every subtree, so that a weighted random element
+// can be drawn in O(log n).
+type WeightedTree struct {
+	tree Tree
+}
+
+// NewWeightedTree creates a new WeightedTree.
+func NewWeightedTree() *WeightedTree {
+	w := new(WeightedTree)
+	w.tree.aug = augmentWeight
+	return w
+}
+
+type weightedItem struct {
+	val    Range
+	weight float64
+	sum    float64 // the sum of the weights in the subtree
+}
+
+func (l *weightedItem) Compare(right Range) int {
+	return l.val.Compare(right.(*weightedItem).val)
+}
+
+func (l *weightedItem) Contains(right Range) bool {
+	return l.val.Contains(right.(*weightedItem).val)
+}
+
+func (l *weightedItem) Union(right Range) Range {
+	r := right.(*weightedItem)
+	return &weightedItem{
+		val:    l.val.Union(r.val),
+		weight: l.weight + r.weight,
+	}
+}
+
+func weightOf(n *avlNode) float64 {
+	if n == nil {
+		return 0
+	}
+	return n.val.(*weightedItem).sum
+}
+
+func augmentWeight(n *avlNode) {
+	item := n.val.(*weightedItem)
+	item.sum = weightOf(n.left) + item.weight + weightOf(n.right)
+}
+
+func checkWeight(weight float64) {
+	if weight < 0 || math.IsNaN(weight) || math.IsInf(weight, 0) {
+		panic("avl: invalid weight")
+	}
+}
+
+// Insert a new Range with the weight into the tree. It panics if the weight is
+// negative, NaN or infinite.
+//
+// Like Tree.Insert, it's a no-op if the tree already contains the <val>, use
+// SetWeight to change the weight of an existing element. If the <val> is
+// merged into an existing element via Range.Union, the merged element carries
+// the sum of both weights.
+func (w *WeightedTree) Insert(val Range, weight float64) {
+	checkWeight(weight)
+	w.tree.Insert(&weightedItem{val: val, weight: weight})
+}
+
+// Search returns true if the tree contains the <val>.
+func (w *WeightedTree) Search(val Range) bool {
+	return w.tree.Search(&weightedItem{val: val})
+}
+
+// Delete removes the element which contains the <val>, and returns false if
+// there is no such element.
+func (w *WeightedTree) Delete(val Range) bool {
+	return w.tree.Delete(&weightedItem{val: val})
+}
+
+// Weight returns the weight of the element which contains the <val>.
+func (w *WeightedTree) Weight(val Range) (float64, bool) {
+	x := w.tree.root.find(&weightedItem{val: val})
+	if x == nil || !x.val.Contains(&weightedItem{val: val}) {
+		return 0, false
+	}
+	return x.val.(*weightedItem).weight, true
+}
+
+// SetWeight updates the weight of the element which contains the <val> in
+// place, and returns false if there is no such element. It panics if the
+// weight is negative, NaN or infinite.
+func (w *WeightedTree) SetWeight(val Range, weight float64) bool {
+	checkWeight(weight)
+	item := &weightedItem{val: val}
+	x := w.tree.root.find(item)
+	if x == nil || !x.val.Contains(item) {
+		return false
+	}
+
+	x.val.(*weightedItem).weight = weight
+	x.augmentUp(w.tree.aug)
+	return true
+}
+
+// TotalWeight returns the sum of the weights of all the elements.
+func (w *WeightedTree) TotalWeight() float64 {
+	return weightOf(w.tree.root)
+}
+
+// SampleWeighted draws a random element, and the probability of each element
+// being drawn is proportional to its weight. It returns false if the tree is
+// empty or all the weights are zero.
+func (w *WeightedTree) SampleWeighted(rng *rand.Rand) (Range, bool) {
+	total := w.TotalWeight()
+	if total <= 0 {
+		return nil, false
+	}
+
+	r := rng.Float64() * total
+	var last *avlNode
+	for n := w.tree.root; n != nil; {
+		item := n.val.(*weightedItem)
+		if item.weight > 0 {
+			last = n
+		}
+
+		left := weightOf(n.left)
+		switch {
+		case r < left:
+			n = n.left
+		case r < left+item.weight:
+			return item.val, true
+		default:
+			r -= left + item.weight
+			n = n.right
+		}
+	}
+
+	// only reachable because of the rounding errors
+	if last == nil {
+		return nil, false
+	}
+	return last.val.(*weightedItem).val, true
+}
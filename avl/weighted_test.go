@@ -0,0 +1,75 @@
+package avl_test
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/sym01/algo/avl"
+)
+
+func TestWeightedTree_SampleWeighted(t *testing.T) {
+	tree := avl.NewWeightedTree()
+	rnd := rand.New(rand.NewSource(1))
+	if _, ok := tree.SampleWeighted(rnd); ok {
+		t.Fatal("unexpected result for sampling from an empty tree")
+	}
+
+	for i := 1; i <= 10; i++ {
+		tree.Insert(&intRange{i, i}, float64(i))
+	}
+	tree.Insert(&intRange{11, 11}, 0)
+	if total := tree.TotalWeight(); total != 55 {
+		t.Fatalf("unexpected total weight, expect 55, got %v", total)
+	}
+
+	const n = 110000
+	counts := make(map[int]int)
+	for i := 0; i < n; i++ {
+		val, ok := tree.SampleWeighted(rnd)
+		if !ok {
+			t.Fatal("unexpected result for sampling")
+		}
+		counts[val.(*intRange).min]++
+	}
+	if counts[11] != 0 {
+		t.Fatalf("the element with zero weight is drawn %d times", counts[11])
+	}
+	for i := 1; i <= 10; i++ {
+		expected := float64(n) * float64(i) / 55
+		if math.Abs(float64(counts[i])-expected) > expected*0.05 {
+			t.Errorf("unexpected count for %d, expect about %v, got %d", i, expected, counts[i])
+		}
+	}
+}
+
+func TestWeightedTree_SetWeight(t *testing.T) {
+	tree := avl.NewWeightedTree()
+	for i := 0; i < 100; i++ {
+		tree.Insert(&intRange{i, i}, 1)
+	}
+
+	for i := 0; i < 100; i++ {
+		if i != 42 && !tree.SetWeight(&intRange{i, i}, 0) {
+			t.Fatalf("unexpected result for setting the weight of %d", i)
+		}
+	}
+	if tree.SetWeight(&intRange{100, 100}, 1) {
+		t.Fatal("unexpected result for setting the weight of a missing element")
+	}
+	if w, ok := tree.Weight(&intRange{42, 42}); w != 1 || !ok {
+		t.Fatalf("unexpected weight for 42, got %v, %v", w, ok)
+	}
+
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		if val, ok := tree.SampleWeighted(rnd); !ok || val.(*intRange).min != 42 {
+			t.Fatalf("unexpected sample, got %v, %v", val, ok)
+		}
+	}
+
+	tree.Delete(&intRange{42, 42})
+	if _, ok := tree.SampleWeighted(rnd); ok || tree.TotalWeight() != 0 {
+		t.Fatal("unexpected result for sampling with zero weights")
+	}
+}
@@ -0,0 +1,138 @@
+//go:build go1.18
+// +build go1.18
+
+package ipfilter
+
+import (
+	"bytes"
+	"net"
+	"net/netip"
+)
+
+// IPRange is a range of IPs from From to To, both inclusive. The From and To
+// are always in the same address family.
+type IPRange struct {
+	From netip.Addr
+	To   netip.Addr
+}
+
+// String returns the range notation of r, like "10.0.0.1-10.0.0.50".
+func (r IPRange) String() string {
+	return r.From.String() + "-" + r.To.String()
+}
+
+// Ranges returns the disjoint ranges of the filter in order, and the adjacent
+// ranges are coalesced. The IPv4 ranges are returned as IPv4 addresses rather
+// than IP4-mapped IPv6 addresses, so a range covering both the IPv4 and IPv6
+// addresses, like "::/0", is split at the boundaries of the IPv4 addresses.
+func (f *IPFilter) Ranges() []IPRange {
+	var ranges []IPRange
+	for _, r := range f.coalesced() {
+		ranges = appendRanges(ranges, r)
+	}
+	return ranges
+}
+
+// coalesced returns the disjoint ranges of the filter in order, and the
+// adjacent ranges are coalesced.
+func (f *IPFilter) coalesced() []*cidr {
+	var ret []*cidr
+	c := f.tree.Cursor()
+	for ok := c.First(); ok; ok = c.Next() {
+		r := c.Value().(*cidr)
+		if n := len(ret); n != 0 && bytes.Equal(nextIP(ret[n-1].max), r.min) {
+			ret[n-1] = &cidr{min: ret[n-1].min, max: r.max}
+			continue
+		}
+		ret = append(ret, r)
+	}
+	return ret
+}
+
+// appendRanges appends r to ranges, and r is split at the boundaries of the
+// IPv4 addresses.
+func appendRanges(ranges []IPRange, r *cidr) []IPRange {
+	min, max := r.min, r.max
+	if bytes.Compare(min, v4MappedMin) < 0 {
+		if bytes.Compare(max, v4MappedMin) < 0 {
+			return append(ranges, newIPRange(min, max))
+		}
+		ranges = append(ranges, newIPRange(min, prevIP(v4MappedMin)))
+		min = v4MappedMin
+	}
+	if bytes.Compare(min, v4MappedMax) <= 0 {
+		if bytes.Compare(max, v4MappedMax) <= 0 {
+			return append(ranges, newIPRange(min, max))
+		}
+		ranges = append(ranges, newIPRange(min, v4MappedMax))
+		min = nextIP(v4MappedMax)
+	}
+	return append(ranges, newIPRange(min, max))
+}
+
+func newIPRange(min, max net.IP) IPRange {
+	from, _ := netip.AddrFromSlice(min)
+	to, _ := netip.AddrFromSlice(max)
+	return IPRange{
+		From: from.Unmap(),
+		To:   to.Unmap(),
+	}
+}
+
+// Prefixes returns the minimal set of CIDR prefixes in order, which covers
+// exactly the same IPs as the filter. It's useful for aggregating and
+// deduplicating blocklists.
+//
+// The prefixes within ::ffff:0:0/96 are returned as IPv4 prefixes, while a
+// prefix covering both the IPv4 and IPv6 addresses, like "::/0", is kept as
+// an IPv6 prefix rather than split like Ranges.
+func (f *IPFilter) Prefixes() []netip.Prefix {
+	var prefixes []netip.Prefix
+	for _, r := range f.coalesced() {
+		from, _ := netip.AddrFromSlice(r.min)
+		to, _ := netip.AddrFromSlice(r.max)
+		prefixes = appendPrefixes(prefixes, IPRange{From: from, To: to})
+	}
+
+	for i, p := range prefixes {
+		if p.Addr().Is4In6() && p.Bits() >= (net.IPv6len-net.IPv4len)*8 {
+			prefixes[i] = netip.PrefixFrom(p.Addr().Unmap(), p.Bits()-(net.IPv6len-net.IPv4len)*8)
+		}
+	}
+	return prefixes
+}
+
+// appendPrefixes appends the minimal set of CIDR prefixes covering r.
+func appendPrefixes(prefixes []netip.Prefix, r IPRange) []netip.Prefix {
+	from := r.From
+	for {
+		// the largest prefix starting from the from, and not exceeding the r
+		var p netip.Prefix
+		var last netip.Addr
+		for bits := 0; bits <= from.BitLen(); bits++ {
+			p = netip.PrefixFrom(from, bits)
+			if p.Masked().Addr() != from {
+				continue
+			}
+			if last = lastAddr(p); last.Compare(r.To) <= 0 {
+				break
+			}
+		}
+
+		prefixes = append(prefixes, p)
+		if last == r.To {
+			return prefixes
+		}
+		from = last.Next()
+	}
+}
+
+// lastAddr returns the last IP of the prefix.
+func lastAddr(p netip.Prefix) netip.Addr {
+	ip := p.Addr().AsSlice()
+	for i := p.Bits(); i < len(ip)*8; i++ {
+		ip[i/8] |= 0x80 >> (i % 8)
+	}
+	ret, _ := netip.AddrFromSlice(ip)
+	return ret
+}
@@ -0,0 +1,101 @@
+//go:build go1.18
+// +build go1.18
+
+package ipfilter
+
+import (
+	"fmt"
+	"net/netip"
+	"reflect"
+	"testing"
+)
+
+func TestRanges(t *testing.T) {
+	f := new(IPFilter)
+	for _, addr := range []string{
+		"10.0.0.0/8", "11.0.0.0/8", // adjacent
+		"10.1.0.0/16", // contained
+		"192.168.1.1-192.168.1.10",
+		"2001:db8::/32",
+	} {
+		if err := f.Add(addr); err != nil {
+			t.Fatalf("unexpected error for %s: %s", addr, err)
+		}
+	}
+
+	expected := "[10.0.0.0-11.255.255.255 192.168.1.1-192.168.1.10 2001:db8::-2001:db8:ffff:ffff:ffff:ffff:ffff:ffff]"
+	if ret := fmt.Sprint(f.Ranges()); ret != expected {
+		t.Fatalf("unexpected ranges, expect %s, got %s", expected, ret)
+	}
+
+	if ret := new(IPFilter).Ranges(); len(ret) != 0 {
+		t.Fatalf("expect no range for an empty filter, got %v", ret)
+	}
+}
+
+func TestRanges_acrossFamilies(t *testing.T) {
+	f := new(IPFilter)
+	_ = f.Add("::/0")
+
+	expected := "[::-::fffe:ffff:ffff 0.0.0.0-255.255.255.255 ::1:0:0:0-ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff]"
+	if ret := fmt.Sprint(f.Ranges()); ret != expected {
+		t.Fatalf("unexpected ranges, expect %s, got %s", expected, ret)
+	}
+}
+
+func TestPrefixes(t *testing.T) {
+	testcases := []struct {
+		addrs    []string
+		expected []string
+	}{
+		{
+			[]string{"10.0.0.0/9", "10.128.0.0/9", "10.1.0.0/16"},
+			[]string{"10.0.0.0/8"},
+		},
+		{
+			[]string{"192.168.1.1-192.168.1.10"},
+			[]string{"192.168.1.1/32", "192.168.1.2/31", "192.168.1.4/30", "192.168.1.8/31", "192.168.1.10/32"},
+		},
+		{
+			[]string{"0.0.0.0-255.255.255.255", "2001:db8::/33", "2001:db8:8000::/33"},
+			[]string{"0.0.0.0/0", "2001:db8::/32"},
+		},
+		{
+			[]string{"::ffff:0.0.0.0/96", "::/96"},
+			[]string{"::/96", "0.0.0.0/0"},
+		},
+		{
+			[]string{"::/0"},
+			[]string{"::/0"},
+		},
+		{
+			[]string{"::/64", "2001:db8::/32"},
+			[]string{"::/64", "2001:db8::/32"},
+		},
+		{
+			[]string{"0.0.0.0/0", "::fffe:0:0/96"},
+			[]string{"::fffe:0:0/95"},
+		},
+		{
+			[]string{"::ffff:10.0.0.0/104", "::ffff:11.0.0.0/104"},
+			[]string{"10.0.0.0/7"},
+		},
+	}
+
+	for _, testcase := range testcases {
+		f := new(IPFilter)
+		for _, addr := range testcase.addrs {
+			if err := f.Add(addr); err != nil {
+				t.Fatalf("unexpected error for %s: %s", addr, err)
+			}
+		}
+
+		var expected []netip.Prefix
+		for _, prefix := range testcase.expected {
+			expected = append(expected, netip.MustParsePrefix(prefix))
+		}
+		if ret := f.Prefixes(); !reflect.DeepEqual(ret, expected) {
+			t.Errorf("unexpected prefixes for %v, expect %v, got %v", testcase.addrs, expected, ret)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+//go:build go1.18
+// +build go1.18
+
+package ipfilter
+
+import (
+	"net"
+	"sync"
+)
+
+var (
+	categoriesOnce sync.Once
+	categories     *RouteTable[Category]
+)
+
+// Classify returns the category of the most specific special-purpose address
+// block containing the ip, or CategoryPublic if there is no such block.
+// The IP4-mapped IPv6 addresses are classified as the IPv4 addresses.
+func Classify(ip net.IP) Category {
+	if ip.To16() == nil {
+		return CategoryInvalid
+	}
+
+	categoriesOnce.Do(func() {
+		categories = new(RouteTable[Category])
+		for _, block := range specialBlocks {
+			_ = categories.Insert(block.prefix, block.category)
+		}
+	})
+	if _, category, ok := categories.Lookup(ip); ok {
+		return category
+	}
+	return CategoryPublic
+}
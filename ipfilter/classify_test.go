@@ -0,0 +1,64 @@
+//go:build go1.18
+// +build go1.18
+
+package ipfilter
+
+import (
+	"net"
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	testcases := []struct {
+		ip       string
+		category Category
+	}{
+		{"8.8.8.8", CategoryPublic},
+		{"2001:4860::8888", CategoryPublic},
+		{"0.0.0.0", CategoryUnspecified},
+		{"0.1.2.3", CategoryThisNetwork},
+		{"::", CategoryUnspecified},
+		{"127.0.0.1", CategoryLoopback},
+		{"::ffff:127.0.0.1", CategoryLoopback},
+		{"::1", CategoryLoopback},
+		{"10.1.2.3", CategoryPrivate},
+		{"192.168.1.1", CategoryPrivate},
+		{"fd00::1", CategoryUniqueLocal},
+		{"100.64.0.1", CategorySharedAddress},
+		{"169.254.1.1", CategoryLinkLocal},
+		{"fe80::1", CategoryLinkLocal},
+		{"224.0.0.1", CategoryMulticast},
+		{"ff02::1", CategoryMulticast},
+		{"255.255.255.255", CategoryBroadcast},
+		{"240.0.0.1", CategoryReserved},
+		{"192.0.2.1", CategoryDocumentation},
+		{"198.51.100.1", CategoryDocumentation},
+		{"203.0.113.1", CategoryDocumentation},
+		{"2001:db8::1", CategoryDocumentation},
+		{"198.19.0.1", CategoryBenchmarking},
+		{"2001:2::1", CategoryBenchmarking},
+		{"100::1", CategoryDiscardOnly},
+		{"192.0.0.8", CategoryProtocolAssignment},
+		{"192.0.0.1", CategoryTransition},
+		{"2001::1", CategoryTransition},
+		{"2001:100::1", CategoryProtocolAssignment},
+		{"2002::1", CategoryTransition},
+		{"64:ff9b::1", CategoryTranslation},
+		{"2001:10::1", CategoryORCHID},
+		{"100:0:0:1::1", CategoryProtocolAssignment},
+		{"2001:1::3", CategoryProtocolAssignment},
+		{"2001:30::1", CategoryProtocolAssignment},
+		{"2620:4f:8000::1", CategoryProtocolAssignment},
+		{"2620:4f:8001::1", CategoryPublic},
+		{"5f00::1", CategorySRv6},
+	}
+	for _, testcase := range testcases {
+		if category := Classify(net.ParseIP(testcase.ip)); category != testcase.category {
+			t.Errorf("unexpected category for %s, expect %s, got %s", testcase.ip, testcase.category, category)
+		}
+	}
+
+	if category := Classify(net.IP{1, 2, 3}); category != CategoryInvalid {
+		t.Errorf("unexpected category for an invalid IP: %s", category)
+	}
+}
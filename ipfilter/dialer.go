@@ -0,0 +1,100 @@
+package ipfilter
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// IPSearcher checks if an IP is in a set, such as IPFilter and SafeIPFilter.
+type IPSearcher interface {
+	SearchIP(ip net.IP) (bool, error)
+}
+
+// BlockedError is returned by the dialers of NewDialer and NewTransport, if the
+// address to connect is in the filter. It's usually wrapped in a *net.OpError,
+// so errors.As is necessary to check it.
+type BlockedError struct {
+	Network string
+	Address string
+
+	// IP is the resolved IP of the Address.
+	IP net.IP
+}
+
+func (e *BlockedError) Error() string {
+	return "ipfilter: connection to " + e.Address + " (" + e.Network + ") is blocked"
+}
+
+// DialControl returns a function for net.Dialer.Control, which rejects the
+// connections to the IPs in the f with a *BlockedError.
+//
+// The Control is called with the resolved IP right before connecting, so it
+// cannot be bypassed by the DNS rebinding. The addresses whose IP cannot be
+// parsed are rejected as well.
+func DialControl(f IPSearcher) func(network, address string, c syscall.RawConn) error {
+	return func(network, address string, c syscall.RawConn) error {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			host = address
+		}
+		// strip the zone of IPv6 addresses, like "fe80::1%eth0"
+		if i := strings.LastIndexByte(host, '%'); i >= 0 {
+			host = host[:i]
+		}
+
+		ip := net.ParseIP(host)
+		if ip == nil {
+			return &BlockedError{Network: network, Address: address}
+		}
+		if found, err := f.SearchIP(ip); found || err != nil {
+			return &BlockedError{Network: network, Address: address, IP: ip}
+		}
+		return nil
+	}
+}
+
+// NewDialer returns a copy of the base whose Control rejects the connections
+// to the IPs in the f, see DialControl . The Control of the base, if any, is
+// called after the check. A zero net.Dialer will be used if base is nil.
+//
+// Since Go 1.20, the ControlContext of the base, if any, is wrapped in the
+// same way, as the Control is ignored by net.Dialer if ControlContext is set.
+func NewDialer(f IPSearcher, base *net.Dialer) *net.Dialer {
+	d := new(net.Dialer)
+	if base != nil {
+		*d = *base
+	}
+
+	check := DialControl(f)
+	next := d.Control
+	d.Control = func(network, address string, c syscall.RawConn) error {
+		if err := check(network, address, c); err != nil {
+			return err
+		}
+		if next != nil {
+			return next(network, address, c)
+		}
+		return nil
+	}
+	wrapControlContext(d, check)
+	return d
+}
+
+// NewTransport returns a new http.Transport with the same settings as the
+// http.DefaultTransport, except that it rejects the connections to the IPs
+// in the f, see DialControl .
+//
+// The proxies from the environment are disabled, as the check would be
+// applied to the proxy rather than the target.
+func NewTransport(f IPSearcher) *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = nil
+	t.DialContext = NewDialer(f, &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}).DialContext
+	return t
+}
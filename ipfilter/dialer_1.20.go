@@ -0,0 +1,25 @@
+//go:build go1.20
+// +build go1.20
+
+package ipfilter
+
+import (
+	"context"
+	"net"
+	"syscall"
+)
+
+// wrapControlContext makes the ControlContext of d, if any, call the check
+// first, as net.Dialer ignores the Control if ControlContext is set.
+func wrapControlContext(d *net.Dialer, check func(network, address string, c syscall.RawConn) error) {
+	next := d.ControlContext
+	if next == nil {
+		return
+	}
+	d.ControlContext = func(ctx context.Context, network, address string, c syscall.RawConn) error {
+		if err := check(network, address, c); err != nil {
+			return err
+		}
+		return next(ctx, network, address, c)
+	}
+}
@@ -0,0 +1,46 @@
+//go:build go1.20
+// +build go1.20
+
+package ipfilter
+
+import (
+	"context"
+	"errors"
+	"net"
+	"syscall"
+	"testing"
+)
+
+func TestNewDialer_controlContext(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer ln.Close()
+
+	called := false
+	base := &net.Dialer{
+		ControlContext: func(ctx context.Context, network, address string, c syscall.RawConn) error {
+			called = true
+			return nil
+		},
+	}
+
+	f := new(IPFilter)
+	_ = f.Add("127.0.0.1")
+	if _, err := NewDialer(f, base).Dial("tcp", ln.Addr().String()); !errors.As(err, new(*BlockedError)) {
+		t.Fatalf("expect a BlockedError, got %v", err)
+	}
+	if called {
+		t.Fatal("expect the ControlContext of the base not to be called for a blocked address")
+	}
+
+	conn, err := NewDialer(new(IPFilter), base).Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	conn.Close()
+	if !called {
+		t.Fatal("expect the ControlContext of the base to be called")
+	}
+}
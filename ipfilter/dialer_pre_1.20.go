@@ -0,0 +1,14 @@
+//go:build !go1.20
+// +build !go1.20
+
+package ipfilter
+
+import (
+	"net"
+	"syscall"
+)
+
+// wrapControlContext is a no-op, as net.Dialer has no ControlContext before
+// Go 1.20.
+func wrapControlContext(d *net.Dialer, check func(network, address string, c syscall.RawConn) error) {
+}
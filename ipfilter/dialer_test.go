@@ -0,0 +1,86 @@
+package ipfilter
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"syscall"
+	"testing"
+)
+
+func TestNewTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	// blocked
+	client := &http.Client{Transport: NewTransport(NewNonPublicIPFilter())}
+	_, err := client.Get(server.URL)
+	var blocked *BlockedError
+	if !errors.As(err, &blocked) {
+		t.Fatalf("expect a BlockedError, got %v", err)
+	}
+	if !blocked.IP.Equal(net.ParseIP("127.0.0.1")) {
+		t.Fatalf("unexpected blocked IP: %s", blocked.IP)
+	}
+
+	// allowed
+	client = &http.Client{Transport: NewTransport(new(IPFilter))}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	resp.Body.Close()
+}
+
+func TestNewDialer(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer ln.Close()
+
+	called := false
+	d := NewDialer(NewSafeIPFilter(nil), &net.Dialer{
+		Control: func(network, address string, c syscall.RawConn) error {
+			called = true
+			return nil
+		},
+	})
+	conn, err := d.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	conn.Close()
+	if !called {
+		t.Fatal("expect the Control of the base to be called")
+	}
+
+	f := new(IPFilter)
+	_ = f.Add("127.0.0.1")
+	if _, err := NewDialer(f, nil).Dial("tcp", ln.Addr().String()); !errors.As(err, new(*BlockedError)) {
+		t.Fatalf("expect a BlockedError, got %v", err)
+	}
+}
+
+func TestDialControl(t *testing.T) {
+	f := new(IPFilter)
+	_ = f.Add("fe80::/10")
+	control := DialControl(f)
+
+	testcases := []struct {
+		address string
+		blocked bool
+	}{
+		{"[fe80::1%eth0]:80", true},
+		{"[2001:db8::1]:80", false},
+		{"8.8.8.8:53", false},
+		{"invalid:80", true},
+	}
+	for _, testcase := range testcases {
+		err := control("tcp", testcase.address, nil)
+		if blocked := err != nil; blocked != testcase.blocked {
+			t.Errorf("unexpected result for %s, expect %v, got %v", testcase.address, testcase.blocked, err)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package ipfilter
+
+import (
+	"bytes"
+	"net"
+
+	"github.com/sym01/algo/avl"
+)
+
+var (
+	nat64Prefix   = []byte{0x00, 0x64, 0xff, 0x9b, 0, 0, 0, 0, 0, 0, 0, 0} // 64:ff9b::/96
+	sixToFourPref = []byte{0x20, 0x02}                                     // 2002::/16
+	teredoPrefix  = []byte{0x20, 0x01, 0x00, 0x00}                         // 2001::/32
+	compatPrefix  = make([]byte, 12)                                       // ::/96
+	isatapIDs     = [][]byte{{0x00, 0x00, 0x5e, 0xfe}, {0x02, 0x00, 0x5e, 0xfe}}
+)
+
+// embeddedIPv4 returns the IPv4 addresses embedded in the IPv6 address ip,
+// in a 16-bytes representation. The following formats are recognized:
+//   - NAT64, like 64:ff9b::7f00:1
+//   - 6to4, like 2002:7f00:1::1
+//   - Teredo, whose client address is obfuscated, like 2001:0:4136:e378:8000:63bf:80ff:fffe
+//   - IPv4-compatible, like ::7f00:1, except the unspecified and loopback addresses
+//   - ISATAP, like fe80::5efe:7f00:1
+func embeddedIPv4(ip *[net.IPv6len]byte) (ret [2][net.IPv6len]byte, n int) {
+	add := func(v4 []byte, mask byte) {
+		ret[n][10], ret[n][11] = 0xff, 0xff
+		for i := range v4 {
+			ret[n][12+i] = v4[i] ^ mask
+		}
+		n++
+	}
+
+	switch {
+	case bytes.HasPrefix(ip[:], nat64Prefix):
+		add(ip[12:], 0)
+	case bytes.HasPrefix(ip[:], sixToFourPref):
+		add(ip[2:6], 0)
+	case bytes.HasPrefix(ip[:], teredoPrefix):
+		add(ip[12:], 0xff)
+	case bytes.HasPrefix(ip[:], compatPrefix):
+		if ip[12]|ip[13]|ip[14] != 0 || ip[15] > 1 {
+			add(ip[12:], 0)
+		}
+		return
+	}
+
+	for _, id := range isatapIDs {
+		if bytes.Equal(ip[8:12], id) {
+			add(ip[12:], 0)
+			break
+		}
+	}
+	return
+}
+
+// contains checks if the ip, in a 16-bytes representation, is in the filter.
+// If the CheckEmbeddedIPv4 of the filter is true, the embedded IPv4 addresses
+// are checked as well.
+func (f *IPFilter) contains(ip *[net.IPv6len]byte) bool {
+	if f.search(ip) {
+		return true
+	}
+	if !f.CheckEmbeddedIPv4 {
+		return false
+	}
+
+	embedded, n := embeddedIPv4(ip)
+	for i := 0; i < n; i++ {
+		if f.search(&embedded[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// search checks if the ip, in a 16-bytes representation, is in the filter
+// without memory allocation.
+func (f *IPFilter) search(ip *[net.IPv6len]byte) bool {
+	_, ok := f.tree.SearchFunc(func(val avl.Range) int {
+		r := val.(*cidr)
+		if bytes.Compare(r.max, ip[:]) < 0 {
+			return -1
+		}
+		if bytes.Compare(r.min, ip[:]) > 0 {
+			return 1
+		}
+		return 0
+	})
+	return ok
+}
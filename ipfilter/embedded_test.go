@@ -0,0 +1,48 @@
+package ipfilter
+
+import (
+	"net"
+	"testing"
+)
+
+func TestIPFilter_CheckEmbeddedIPv4(t *testing.T) {
+	f := new(IPFilter)
+	_ = f.Add("127.0.0.0/8")
+	_ = f.Add("192.0.2.0/24")
+
+	testcases := []struct {
+		ip       string
+		embedded bool
+	}{
+		{"64:ff9b::7f00:1", true},                       // NAT64
+		{"64:ff9b::808:808", false},                     // NAT64 of 8.8.8.8
+		{"2002:7f00:1::1", true},                        // 6to4
+		{"2002:808:808::1", false},                      // 6to4 of 8.8.8.8
+		{"2001:0:4136:e378:8000:63bf:3fff:fdd2", true},  // Teredo of 192.0.2.45
+		{"2001:0:4136:e378:8000:63bf:f7f7:f7f7", false}, // Teredo of 8.8.8.8
+		{"::7f00:1", true},                              // IPv4-compatible
+		{"::1", false},                                  // loopback, not IPv4-compatible
+		{"fe80::5efe:7f00:1", true},                     // ISATAP
+		{"2001:db8::200:5efe:c000:201", true},           // ISATAP, universal
+		{"2002:808:808::5efe:7f00:1", true},             // 6to4 with ISATAP
+		{"2001:db8::7f00:1", false},
+	}
+
+	for _, testcase := range testcases {
+		ip := net.ParseIP(testcase.ip)
+		if found, _ := f.SearchIP(ip); found {
+			t.Errorf("expect %s not to be found without CheckEmbeddedIPv4", testcase.ip)
+		}
+	}
+
+	f.CheckEmbeddedIPv4 = true
+	for _, testcase := range testcases {
+		found, err := f.Search(testcase.ip)
+		if err != nil {
+			t.Fatalf("unexpected error for %s: %s", testcase.ip, err)
+		}
+		if found != testcase.embedded {
+			t.Errorf("unexpected result for %s, expect %v, got %v", testcase.ip, testcase.embedded, found)
+		}
+	}
+}
@@ -56,60 +56,254 @@ func (l *cidr) Union(right avl.Range) avl.Range {
 	return ret
 }
 
+// Subtract implements avl.Subtracter .
+func (l *cidr) Subtract(right avl.Range) (ret []avl.Range) {
+	r := right.(*cidr)
+	if bytes.Compare(l.min, r.min) < 0 {
+		ret = append(ret, &cidr{
+			min: l.min,
+			max: prevIP(r.min),
+		})
+	}
+	if bytes.Compare(l.max, r.max) > 0 {
+		ret = append(ret, &cidr{
+			min: nextIP(r.max),
+			max: l.max,
+		})
+	}
+	return
+}
+
+// prevIP returns a new IP right before the ip, which must not be the min IP.
+func prevIP(ip net.IP) net.IP {
+	ret := make(net.IP, len(ip))
+	copy(ret, ip)
+	for i := len(ret) - 1; i >= 0; i-- {
+		ret[i]--
+		if ret[i] != 0xff {
+			break
+		}
+	}
+	return ret
+}
+
+// nextIP returns a new IP right after the ip, which must not be the max IP.
+func nextIP(ip net.IP) net.IP {
+	ret := make(net.IP, len(ip))
+	copy(ret, ip)
+	for i := len(ret) - 1; i >= 0; i-- {
+		ret[i]++
+		if ret[i] != 0 {
+			break
+		}
+	}
+	return ret
+}
+
+func minIP(l, r net.IP) net.IP {
+	if bytes.Compare(l, r) < 0 {
+		return l
+	}
+	return r
+}
+
+func maxIP(l, r net.IP) net.IP {
+	if bytes.Compare(l, r) > 0 {
+		return l
+	}
+	return r
+}
+
+var (
+	// the IP4-mapped IPv6 addresses, where the IPv4 addresses are kept in the
+	// filter
+	v4MappedMin = net.ParseIP("::ffff:0.0.0.0")
+	v4MappedMax = net.ParseIP("::ffff:255.255.255.255")
+)
+
+// CIDRMode controls how IPFilter.Add handles the CIDR addresses with host
+// bits set, such as "192.168.1.77/24".
+type CIDRMode int
+
+const (
+	// CIDRNormalize clears the host bits, so "192.168.1.77/24" is treated
+	// as "192.168.1.0/24". It's the default mode.
+	CIDRNormalize CIDRMode = iota
+
+	// CIDRStrict rejects the CIDR addresses with host bits set.
+	CIDRStrict
+)
+
+// HostBitsError is returned by IPFilter.Add in CIDRStrict mode, if the CIDR
+// address has host bits set.
+type HostBitsError struct {
+	// Addr is the CIDR address passed to Add.
+	Addr string
+
+	// Network is the CIDR address without host bits.
+	Network string
+}
+
+func (e *HostBitsError) Error() string {
+	return "ipfilter: CIDR address " + e.Addr + " has host bits set, use " + e.Network
+}
+
+// NormalizedCIDR records a CIDR address whose host bits had been cleared by
+// IPFilter.Add in CIDRNormalize mode.
+type NormalizedCIDR struct {
+	// Addr is the CIDR address passed to Add.
+	Addr string
+
+	// Network is the CIDR address actually added.
+	Network string
+}
+
 // IPFilter is a high performance, AVL-based IP filter.
 // The filter can be used for filtering any IPv4, IPv6 and
 // IP4-mapped IPv6 addresses.
 //
 // It's thread-safe for read ops. But if you need to read and write at the same
-// time, a RWLock is necessary.
+// time, a RWLock is necessary, or use SafeIPFilter to reload it.
 type IPFilter struct {
-	tree avl.Tree
+	// CIDRMode controls how Add handles the CIDR addresses with host bits
+	// set. CIDRNormalize will be used by default.
+	CIDRMode CIDRMode
+
+	// CheckEmbeddedIPv4 makes the searches check the IPv4 addresses embedded
+	// in the IPv6 transition formats as well, that is, NAT64 64:ff9b::/96,
+	// 6to4 2002::/16, Teredo 2001::/32, IPv4-compatible ::/96 and ISATAP.
+	// For instance, 64:ff9b::7f00:1 is found if 127.0.0.1 is in the filter.
+	CheckEmbeddedIPv4 bool
+
+	tree       avl.Tree
+	normalized []NormalizedCIDR
 }
 
 // Add an IP or a CIDR address into the filter.
 // The addr can be a IP, such as "192.168.0.1", or a CIDR notation,
-// like "192.0.2.0/24" or "2001:db8::/32" .
+// like "192.0.2.0/24" or "2001:db8::/32" . A CIDR address with host bits set
+// is handled according to the CIDRMode of the filter.
+//
+// The following notations are also accepted for both IPv4 and IPv6:
+//   - a range, like "10.0.0.1-10.0.0.50" or "2001:db8::1 - 2001:db8::ff"
+//   - a wildcard, like "192.168.*.*" or "2001:db8:*:*:*:*:*:*", where only
+//     the trailing components can be wildcards
+//   - an address with a netmask, like "10.0.0.0 255.255.0.0", which is
+//     handled as the CIDR notation
+//
+// The ranges and wildcards are inserted as is, rather than being expanded
+// into CIDR addresses.
 //
 // To add mutliple IP / CIDR addresses into the filter, you can simply call it
 // multi times.
 func (f *IPFilter) Add(addr string) error {
-	if strings.ContainsRune(addr, '/') {
-		return f.addCIDR(addr)
+	r, err := f.parse(addr, true)
+	if err != nil {
+		return err
+	}
+
+	f.tree.Insert(r)
+	return nil
+}
+
+// Remove an IP or a CIDR address from the filter. The addr accepts the same
+// notations as Add.
+//
+// Only the exact range of the addr is removed, so the ranges partially
+// overlapping it are trimmed or split. For instance, removing "10.1.0.0/16"
+// from "10.0.0.0/8" leaves 10.0.0.0 - 10.0.255.255 and
+// 10.2.0.0 - 10.255.255.255 in the filter.
+func (f *IPFilter) Remove(addr string) error {
+	r, err := f.parse(addr, false)
+	if err != nil {
+		return err
+	}
+
+	f.tree.RemoveRange(r)
+	return nil
+}
+
+// parse parses the addr as an IP or a CIDR address. If record is true, the
+// normalized CIDR addresses will be recorded.
+func (f *IPFilter) parse(addr string, record bool) (*cidr, error) {
+	r, network, err := parseAddr(addr, f.CIDRMode)
+	if err != nil {
+		return nil, err
+	}
+
+	if record && network != "" {
+		f.normalized = append(f.normalized, NormalizedCIDR{
+			Addr:    addr,
+			Network: network,
+		})
+	}
+	return r, nil
+}
+
+// parseAddr parses the addr in any notation accepted by IPFilter.Add .
+// If the addr is a CIDR address with host bits set, network is the CIDR
+// address without host bits.
+func parseAddr(addr string, mode CIDRMode) (r *cidr, network string, err error) {
+	switch {
+	case strings.ContainsRune(addr, '/'):
+		ip, ipNet, err := net.ParseCIDR(addr)
+		if err != nil {
+			return nil, "", err
+		}
+		return parseCIDR(addr, ip, ipNet, mode)
+	case strings.ContainsRune(addr, '-'):
+		r, err = parseRange(addr)
+		return
+	case strings.ContainsRune(addr, '*'):
+		r, err = parseWildcard(addr)
+		return
+	case len(strings.Fields(addr)) == 2:
+		ip, ipNet, err := parseNetmask(addr)
+		if err != nil {
+			return nil, "", err
+		}
+		return parseCIDR(addr, ip, ipNet, mode)
 	}
 
 	ip := net.ParseIP(addr)
 	if ip == nil {
-		return &net.ParseError{Type: "IP address", Text: addr}
+		return nil, "", &net.ParseError{Type: "IP address", Text: addr}
 	}
 
 	// to a 16-bytes representation
 	ip = ip.To16()
-	f.tree.Insert(&cidr{
+	return &cidr{
 		min: ip,
 		max: ip,
-	})
-	return nil
+	}, "", nil
 }
 
-func (f *IPFilter) addCIDR(addr string) error {
-	ip, ipNet, err := net.ParseCIDR(addr)
-	if err != nil {
-		return err
-	}
-
-	max := ipNet.IP.To16()
-	if len(max) < len(ipNet.Mask) {
-		return &net.ParseError{Type: "CIDR address", Text: addr}
+// parseCIDR converts the parsed CIDR address into a cidr.
+func parseCIDR(addr string, ip net.IP, ipNet *net.IPNet, mode CIDRMode) (r *cidr, network string, err error) {
+	if !ip.Equal(ipNet.IP) {
+		if mode == CIDRStrict {
+			return nil, "", &HostBitsError{Addr: addr, Network: ipNet.String()}
+		}
+		network = ipNet.String()
 	}
 
+	min := make(net.IP, net.IPv6len)
+	copy(min, ipNet.IP.To16())
+	max := make(net.IP, net.IPv6len)
+	copy(max, min)
 	for i := 1; i <= len(ipNet.Mask); i++ {
 		max[len(max)-i] |= ^ipNet.Mask[len(ipNet.Mask)-i]
 	}
-	f.tree.Insert(&cidr{
-		min: ip.To16(),
+	return &cidr{
+		min: min,
 		max: max,
-	})
-	return nil
+	}, network, nil
+}
+
+// Normalized returns all the CIDR addresses whose host bits had been cleared
+// by Add in CIDRNormalize mode, in the order they were added.
+func (f *IPFilter) Normalized() []NormalizedCIDR {
+	return f.normalized
 }
 
 // Search parses addr as an IP address, and checks if it's in the filter.
@@ -121,8 +315,9 @@ func (f *IPFilter) Search(addr string) (bool, error) {
 		return false, &net.ParseError{Type: "IP address", Text: addr}
 	}
 
-	ip = ip.To16()
-	return f.tree.Search(&cidr{ip, ip}), nil
+	var ip16 [net.IPv6len]byte
+	copy(ip16[:], ip.To16())
+	return f.contains(&ip16), nil
 }
 
 // SearchIP checks if the ip is in the filter.
@@ -133,7 +328,10 @@ func (f *IPFilter) SearchIP(ip net.IP) (bool, error) {
 	if ip == nil {
 		return false, &net.ParseError{Type: "IP address", Text: ip.String()}
 	}
-	return f.tree.Search(&cidr{ip, ip}), nil
+
+	var ip16 [net.IPv6len]byte
+	copy(ip16[:], ip)
+	return f.contains(&ip16), nil
 }
 
 // NewNonGlobalUnicastIPFilter returns a new IPFilter which can filter out all
@@ -160,18 +358,17 @@ func NewNonGlobalUnicastIPFilter() *IPFilter {
 
 // NewNonPublicIPFilter returns a new IPFilter which can filter out all the
 // non-public IPv4 and IPv6 IPs, such as private addresses.
+//
+// All the blocks of the IANA Special-Purpose Address Registries which are not
+// globally reachable are filtered out, see RFC 6890. A globally reachable
+// block inside a filtered one, like the PCP anycast 192.0.0.9/32 inside
+// 192.0.0.0/24, is filtered out as well.
 func NewNonPublicIPFilter() *IPFilter {
 	f := NewNonGlobalUnicastIPFilter()
-	// RFC1918
-	_ = f.Add("10.0.0.0/8")
-	_ = f.Add("172.16.0.0/12")
-	_ = f.Add("192.168.0.0/16")
-
-	_ = f.Add("100.64.0.0/10")
-	_ = f.Add("192.0.0.0/24")
-
-	// IPv6 private
-	_ = f.Add("fc00::/7")
-	_ = f.Add("64:ff9b:1::/48")
+	for _, block := range specialBlocks {
+		if !block.global {
+			_ = f.Add(block.prefix)
+		}
+	}
 	return f
 }
@@ -23,10 +23,23 @@ func TestNewNonPublicIPFilter(t *testing.T) {
 		{"fc12:3456:789a:1::1", true},
 		{"0.0.0.0", true},
 		{"1.1.1.1", false},             // public ip
-		{"::ffff:192.0.2.128", false},  // public IP4-mapped IPv6 address
+		{"::ffff:8.8.4.4", false},      // public IP4-mapped IPv6 address
+		{"::ffff:192.0.2.128", true},   // documentation IP4-mapped IPv6 address
 		{"::ffff:192.168.2.128", true}, // public IP4-mapped IPv6 address
 		{"100.64.0.0", true},
 		{"64:ff9b:1::0.0.0.0", true}, // IPv4/IPv6 translation[16]
+		{"64:ff9b::8.8.8.8", false},  // globally reachable NAT64
+		{"198.18.0.1", true},         // benchmarking
+		{"203.0.113.1", true},        // documentation
+		{"240.0.0.1", true},          // reserved
+		{"2001:db8::1", true},        // documentation
+		{"2001:1::1", true},          // inside 2001::/23
+		{"2001:10::1", true},         // ORCHID
+		{"2002:7f00:1::1", true},     // 6to4
+		{"5f00::1", true},            // SRv6 SIDs
+		{"100:0:0:1::1", true},       // dummy IPv6 prefix
+		{"2620:4f:8000::1", false},   // globally reachable AS112
+		{"2001:4860::8888", false},   // public
 	}
 	for _, testcase := range testcases {
 		found, err := f.Search(testcase.ip)
@@ -110,3 +123,93 @@ func BenchmarkNormalRegexBasedFilter(b *testing.B) {
 		}
 	})
 }
+
+func TestAdd_hostBits(t *testing.T) {
+	f := new(IPFilter)
+	for _, addr := range []string{"192.168.1.77/24", "10.0.0.0/8", "2001:db8::1/32"} {
+		if err := f.Add(addr); err != nil {
+			t.Fatalf("unexpected error for %s: %s", addr, err)
+		}
+	}
+
+	testcases := []struct {
+		ip     string
+		expect bool
+	}{
+		{"192.168.1.0", true},
+		{"192.168.1.5", true},
+		{"192.168.1.255", true},
+		{"192.168.2.0", false},
+		{"2001:db8::", true},
+		{"2001:db9::", false},
+	}
+	for _, testcase := range testcases {
+		if found, _ := f.Search(testcase.ip); found != testcase.expect {
+			t.Errorf("unexpected result for %s, expect %v, got %v",
+				testcase.ip, testcase.expect, found)
+		}
+	}
+
+	expected := []NormalizedCIDR{
+		{"192.168.1.77/24", "192.168.1.0/24"},
+		{"2001:db8::1/32", "2001:db8::/32"},
+	}
+	if ret := f.Normalized(); fmt.Sprint(ret) != fmt.Sprint(expected) {
+		t.Fatalf("unexpected normalized entries, expect %v, got %v", expected, ret)
+	}
+
+	f = &IPFilter{CIDRMode: CIDRStrict}
+	err := f.Add("192.168.1.77/24")
+	if e, ok := err.(*HostBitsError); !ok || e.Network != "192.168.1.0/24" {
+		t.Fatalf("unexpected error for strict mode: %v", err)
+	}
+	if found, _ := f.Search("192.168.1.77"); found {
+		t.Fatal("the rejected CIDR address is added")
+	}
+	if err := f.Add("192.168.1.0/24"); err != nil {
+		t.Fatalf("unexpected error for strict mode: %s", err)
+	}
+}
+
+func TestRemove(t *testing.T) {
+	f := new(IPFilter)
+	for _, addr := range []string{"10.0.0.0/8", "192.168.0.0/16", "2001:db8::/32", "172.16.0.1"} {
+		if err := f.Add(addr); err != nil {
+			t.Fatalf("unexpected error for %s: %s", addr, err)
+		}
+	}
+
+	for _, addr := range []string{"10.1.0.0/16", "192.168.0.0/24", "2001:db8::/48", "172.16.0.1", "8.8.8.8", "192.168.255.255"} {
+		if err := f.Remove(addr); err != nil {
+			t.Fatalf("unexpected error for %s: %s", addr, err)
+		}
+	}
+	if err := f.Remove("invalid"); err == nil {
+		t.Fatal("expect an error for an invalid address")
+	}
+
+	testcases := []struct {
+		ip     string
+		expect bool
+	}{
+		{"10.0.0.0", true},
+		{"10.0.255.255", true},
+		{"10.1.0.0", false},
+		{"10.1.255.255", false},
+		{"10.2.0.0", true},
+		{"10.255.255.255", true},
+		{"192.168.0.255", false},
+		{"192.168.1.0", true},
+		{"192.168.255.254", true},
+		{"192.168.255.255", false},
+		{"172.16.0.1", false},
+		{"2001:db8::1", false},
+		{"2001:db8:1::", true},
+	}
+	for _, testcase := range testcases {
+		if found, _ := f.Search(testcase.ip); found != testcase.expect {
+			t.Errorf("unexpected result for %s, expect %v, got %v",
+				testcase.ip, testcase.expect, found)
+		}
+	}
+}
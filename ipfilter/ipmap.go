@@ -0,0 +1,172 @@
+//go:build go1.18
+// +build go1.18
+
+package ipfilter
+
+import (
+	"bytes"
+	"net"
+
+	"github.com/sym01/algo/avl"
+)
+
+// MergeFunc returns the value for the overlapping part, when an address
+// inserted into an IPMap overlaps an existing one.
+type MergeFunc[V any] func(old, new V) V
+
+// KeepOld is a MergeFunc which keeps the existing value.
+func KeepOld[V any](old, new V) V { return old }
+
+// Replace is a MergeFunc which replaces the existing value with the new one.
+func Replace[V any](old, new V) V { return new }
+
+// IPMatch is the result of IPMap.Lookup .
+type IPMatch[V any] struct {
+	Value V
+
+	// Prefixes are the addresses passed to IPMap.Insert which the IP matches,
+	// such as "10.0.0.0/8", in the order of insertion. If the IP is in the
+	// overlapping part of multiple addresses, the Value is merged from all of
+	// them, e.g. it's from the last one for Replace, and the first one for
+	// KeepOld. The Prefixes must not be modified.
+	Prefixes []string
+}
+
+// IPMap is an IP-to-value lookup map, based on the same AVL tree of IP ranges
+// as IPFilter. Each range carries a value, and the overlapping ranges are
+// split so that every IP matches only one range.
+//
+// It's thread-safe for read ops. But if you need to read and write at the same
+// time, a RWLock is necessary.
+type IPMap[V any] struct {
+	tree  avl.Tree
+	merge MergeFunc[V]
+}
+
+// NewIPMap creates a new IPMap. The merge decides the value of the
+// overlapping part of two inserted addresses, Replace will be used if nil.
+func NewIPMap[V any](merge MergeFunc[V]) *IPMap[V] {
+	if merge == nil {
+		merge = Replace[V]
+	}
+	return &IPMap[V]{merge: merge}
+}
+
+type ipEntry[V any] struct {
+	rng   cidr
+	value V
+
+	// prefixes are the addresses contributing to the value, which are shared
+	// by the pieces of an entry, and must not be modified.
+	prefixes []string
+}
+
+// Compare implements avl.Range .
+func (l *ipEntry[V]) Compare(right avl.Range) int {
+	return l.rng.Compare(&right.(*ipEntry[V]).rng)
+}
+
+// Contains implements avl.Range .
+func (l *ipEntry[V]) Contains(right avl.Range) bool {
+	return l.rng.Contains(&right.(*ipEntry[V]).rng)
+}
+
+// Union implements avl.Range . The entries never overlap, so it's never
+// called by the tree.
+func (l *ipEntry[V]) Union(right avl.Range) avl.Range {
+	return l
+}
+
+// Insert an address with the value into the map. The addr accepts the same
+// notations as IPFilter.Add, and the host bits of the CIDR addresses are
+// always cleared.
+//
+// If the addr overlaps the existing addresses, the overlapping parts get the
+// value returned by the MergeFunc of the map.
+func (m *IPMap[V]) Insert(addr string, value V) error {
+	r, _, err := parseAddr(addr, CIDRNormalize)
+	if err != nil {
+		return err
+	}
+
+	// collect the overlapping entries in order
+	var overlapped []*ipEntry[V]
+	key := &ipEntry[V]{rng: *r}
+	c := m.tree.Cursor()
+	for ok := c.Seek(key); ok && c.Value().Compare(key) == 0; ok = c.Next() {
+		overlapped = append(overlapped, c.Value().(*ipEntry[V]))
+	}
+
+	var pieces []*ipEntry[V]
+	prefixes := []string{addr}
+	cur := r.min
+	for _, old := range overlapped {
+		m.tree.Delete(old)
+
+		// the parts of the old entry out of the new range
+		for _, rest := range old.rng.Subtract(r) {
+			pieces = append(pieces, &ipEntry[V]{
+				rng:      *rest.(*cidr),
+				value:    old.value,
+				prefixes: old.prefixes,
+			})
+		}
+
+		// the overlapping part
+		pieces = append(pieces, &ipEntry[V]{
+			rng:      cidr{min: maxIP(old.rng.min, r.min), max: minIP(old.rng.max, r.max)},
+			value:    m.merge(old.value, value),
+			prefixes: append(old.prefixes[:len(old.prefixes):len(old.prefixes)], addr),
+		})
+
+		// the gap before the old entry
+		if bytes.Compare(cur, old.rng.min) < 0 {
+			pieces = append(pieces, &ipEntry[V]{
+				rng:      cidr{min: cur, max: prevIP(old.rng.min)},
+				value:    value,
+				prefixes: prefixes,
+			})
+		}
+		if bytes.Compare(old.rng.max, r.max) >= 0 {
+			cur = nil
+			break
+		}
+		cur = nextIP(old.rng.max)
+	}
+	if cur != nil {
+		pieces = append(pieces, &ipEntry[V]{
+			rng:      cidr{min: cur, max: r.max},
+			value:    value,
+			prefixes: prefixes,
+		})
+	}
+
+	for _, piece := range pieces {
+		m.tree.Insert(piece)
+	}
+	return nil
+}
+
+// Lookup returns the value of the range which the ip is in, and false if the
+// ip is not in the map.
+func (m *IPMap[V]) Lookup(ip net.IP) (match IPMatch[V], ok bool) {
+	ip = ip.To16()
+	if ip == nil {
+		return
+	}
+
+	val, ok := m.tree.Get(&ipEntry[V]{rng: cidr{ip, ip}})
+	if !ok {
+		return
+	}
+	entry := val.(*ipEntry[V])
+	return IPMatch[V]{
+		Value:    entry.value,
+		Prefixes: entry.prefixes,
+	}, true
+}
+
+// Len returns the number of the disjoint ranges in the map.
+func (m *IPMap[V]) Len() int {
+	return m.tree.Len()
+}
@@ -0,0 +1,106 @@
+//go:build go1.18
+// +build go1.18
+
+package ipfilter
+
+import (
+	"fmt"
+	"net"
+	"testing"
+)
+
+func TestIPMap(t *testing.T) {
+	m := NewIPMap[string](nil)
+	inserts := []struct {
+		addr  string
+		value string
+	}{
+		{"10.0.0.0/8", "corp"},
+		{"10.1.0.0/16", "lab"},
+		{"10.1.2.0-10.2.0.255", "staging"},
+		{"192.168.0.0/16", "home"},
+		{"2001:db8::/32", "docs"},
+	}
+	for _, item := range inserts {
+		if err := m.Insert(item.addr, item.value); err != nil {
+			t.Fatalf("unexpected error for %s: %s", item.addr, err)
+		}
+	}
+	if err := m.Insert("invalid", "x"); err == nil {
+		t.Fatal("expect an error for an invalid address")
+	}
+
+	testcases := []struct {
+		ip       string
+		found    bool
+		value    string
+		prefixes string
+	}{
+		{"10.0.0.1", true, "corp", "[10.0.0.0/8]"},
+		{"10.1.0.1", true, "lab", "[10.0.0.0/8 10.1.0.0/16]"},
+		{"10.1.2.3", true, "staging", "[10.0.0.0/8 10.1.0.0/16 10.1.2.0-10.2.0.255]"},
+		{"10.1.255.255", true, "staging", "[10.0.0.0/8 10.1.0.0/16 10.1.2.0-10.2.0.255]"},
+		{"10.2.0.0", true, "staging", "[10.0.0.0/8 10.1.2.0-10.2.0.255]"},
+		{"10.2.1.0", true, "corp", "[10.0.0.0/8]"},
+		{"10.255.255.255", true, "corp", "[10.0.0.0/8]"},
+		{"192.168.1.1", true, "home", "[192.168.0.0/16]"},
+		{"2001:db8::1", true, "docs", "[2001:db8::/32]"},
+		{"11.0.0.0", false, "", "[]"},
+		{"::1", false, "", "[]"},
+	}
+	for _, testcase := range testcases {
+		match, found := m.Lookup(net.ParseIP(testcase.ip))
+		prefixes := fmt.Sprint(match.Prefixes)
+		if found != testcase.found || match.Value != testcase.value || prefixes != testcase.prefixes {
+			t.Errorf("unexpected result for %s, expect %v %s %s, got %v %s %s",
+				testcase.ip, testcase.found, testcase.value, testcase.prefixes,
+				found, match.Value, prefixes)
+		}
+	}
+	if _, found := m.Lookup(nil); found {
+		t.Error("unexpected result for a nil IP")
+	}
+}
+
+func TestIPMap_merge(t *testing.T) {
+	m := NewIPMap(func(old, new []string) []string {
+		return append(append([]string(nil), old...), new...)
+	})
+	_ = m.Insert("10.0.0.0/24", []string{"a"})
+	_ = m.Insert("10.0.0.128/25", []string{"b"})
+	_ = m.Insert("10.0.0.0-10.0.1.0", []string{"c"})
+
+	testcases := []struct {
+		ip    string
+		value string
+	}{
+		{"10.0.0.1", "[a c]"},
+		{"10.0.0.200", "[a b c]"},
+		{"10.0.1.0", "[c]"},
+	}
+	for _, testcase := range testcases {
+		match, _ := m.Lookup(net.ParseIP(testcase.ip))
+		if ret := fmt.Sprint(match.Value); ret != testcase.value {
+			t.Errorf("unexpected value for %s, expect %s, got %s", testcase.ip, testcase.value, ret)
+		}
+	}
+	if m.Len() != 3 {
+		t.Errorf("unexpected number of ranges, got %d", m.Len())
+	}
+
+	k := NewIPMap(KeepOld[int])
+	_ = k.Insert("10.0.0.0/8", 1)
+	_ = k.Insert("10.0.0.0/16", 2)
+	if match, _ := k.Lookup(net.ParseIP("10.0.0.1")); match.Value != 1 ||
+		fmt.Sprint(match.Prefixes) != "[10.0.0.0/8 10.0.0.0/16]" {
+		t.Errorf("unexpected result for KeepOld, got %v", match)
+	}
+
+	r := NewIPMap(Replace[string])
+	_ = r.Insert("10.1.0.0/16", "lab")
+	_ = r.Insert("10.0.0.0/8", "corp")
+	if match, _ := r.Lookup(net.ParseIP("10.1.0.1")); match.Value != "corp" ||
+		fmt.Sprint(match.Prefixes) != "[10.1.0.0/16 10.0.0.0/8]" {
+		t.Errorf("unexpected result for Replace, got %v", match)
+	}
+}
@@ -0,0 +1,119 @@
+package ipfilter
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+)
+
+// LineError is the error for an invalid line in a blocklist.
+type LineError struct {
+	// Line is the line number, starting from 1.
+	Line int
+
+	// Text is the content of the line.
+	Text string
+
+	Err error
+}
+
+func (e *LineError) Error() string {
+	return fmt.Sprintf("ipfilter: line %d: %s", e.Line, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *LineError) Unwrap() error {
+	return e.Err
+}
+
+// LoadErrors is the list of the invalid lines found by IPFilter.LoadFrom .
+type LoadErrors []*LineError
+
+func (e LoadErrors) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+	return fmt.Sprintf("%s (and %d more errors)", e[0], len(e)-1)
+}
+
+// LoadFrom reads a blocklist from r, and adds all the addresses into the
+// filter. The blocklist contains one IP or CIDR address per line, such as
+// Spamhaus DROP and FireHOL netsets:
+//
+//	# comments start with '#' or ';'
+//	192.0.2.1
+//	198.51.100.0/24 ; trailing annotations are ignored
+//
+// Every notation accepted by Add can be used, including the ranges like
+// "10.0.0.1 - 10.0.0.50" and the netmasks like "10.0.0.0 255.255.0.0".
+//
+// Blank lines, comments, and anything after the address are ignored.
+//
+// If continueOnError is false, LoadFrom stops at the first invalid line.
+// Otherwise, the invalid lines are skipped. In both cases, the invalid lines
+// are reported as LoadErrors. Any error from reading r is returned as is.
+func (f *IPFilter) LoadFrom(r io.Reader, continueOnError bool) error {
+	var errs LoadErrors
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		addr := parseLine(scanner.Text())
+		if addr == "" {
+			continue
+		}
+
+		if err := f.Add(addr); err != nil {
+			errs = append(errs, &LineError{
+				Line: line,
+				Text: scanner.Text(),
+				Err:  err,
+			})
+			if !continueOnError {
+				return errs
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// LoadFile reads a blocklist from the file, see LoadFrom for details.
+func (f *IPFilter) LoadFile(path string, continueOnError bool) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return f.LoadFrom(file, continueOnError)
+}
+
+// parseLine returns the address in a line of a blocklist, or an empty string
+// if there is nothing.
+func parseLine(line string) string {
+	if idx := strings.IndexAny(line, "#;"); idx >= 0 {
+		line = line[:idx]
+	}
+
+	fields := strings.Fields(line)
+	switch {
+	case len(fields) == 0:
+		return ""
+	case len(fields) >= 3 && fields[1] == "-":
+		// a range with spaces, like "10.0.0.1 - 10.0.0.50"
+		return fields[0] + "-" + fields[2]
+	case len(fields) >= 2 && net.ParseIP(fields[1]) != nil:
+		// an address with a netmask, like "10.0.0.0 255.255.0.0"
+		return fields[0] + " " + fields[1]
+	default:
+		return fields[0]
+	}
+}
@@ -0,0 +1,103 @@
+package ipfilter
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const blocklist = `; Spamhaus DROP List
+; Last-Modified: Fri, 1 Apr 2022 00:00:00 GMT
+1.10.16.0/20 ; SBL256894
+1.19.0.0/16 ; SBL434604
+
+# FireHOL netset
+	2.56.192.0/22
+192.0.2.1	# a single IP
+invalid-address
+2001:db8::/32
+10.0.0.0/33 ; invalid prefix
+`
+
+func TestLoadFrom(t *testing.T) {
+	f := new(IPFilter)
+	err := f.LoadFrom(strings.NewReader(blocklist), true)
+
+	var errs LoadErrors
+	if !errors.As(err, &errs) || len(errs) != 2 {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if errs[0].Line != 9 || errs[0].Text != "invalid-address" || errs[1].Line != 11 {
+		t.Fatalf("unexpected line errors: %v, %v", errs[0], errs[1])
+	}
+	var parseErr *net.ParseError
+	if !errors.As(errs[0], &parseErr) {
+		t.Fatalf("unexpected underlying error: %v", errs[0].Err)
+	}
+
+	for _, ip := range []string{"1.10.16.1", "1.19.255.255", "2.56.195.0", "192.0.2.1", "2001:db8::1"} {
+		if found, _ := f.Search(ip); !found {
+			t.Errorf("unexpected result for %s", ip)
+		}
+	}
+	if found, _ := f.Search("192.0.2.2"); found {
+		t.Error("unexpected result for 192.0.2.2")
+	}
+}
+
+func TestLoadFrom_stopOnError(t *testing.T) {
+	f := new(IPFilter)
+	err := f.LoadFrom(strings.NewReader(blocklist), false)
+
+	var errs LoadErrors
+	if !errors.As(err, &errs) || len(errs) != 1 || errs[0].Line != 9 {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if found, _ := f.Search("2001:db8::1"); found {
+		t.Error("the lines after the invalid line are loaded")
+	}
+	if found, _ := f.Search("192.0.2.1"); !found {
+		t.Error("the lines before the invalid line are not loaded")
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ipfilter")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "drop.txt")
+	if err := ioutil.WriteFile(path, []byte("192.0.2.0/24 ; SBL1\n"), 0o644); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	f := new(IPFilter)
+	if err := f.LoadFile(path, false); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if found, _ := f.Search("192.0.2.42"); !found {
+		t.Error("unexpected result for 192.0.2.42")
+	}
+	if err := f.LoadFile(filepath.Join(dir, "not-exist"), false); err == nil {
+		t.Error("expect an error for a missing file")
+	}
+}
+
+func TestLoadFrom_notations(t *testing.T) {
+	f := new(IPFilter)
+	list := "10.0.0.1 - 10.0.0.50 # range\n10.1.0.0 255.255.0.0 ; netmask\n192.168.*.* partner list\n"
+	if err := f.LoadFrom(strings.NewReader(list), false); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	for _, ip := range []string{"10.0.0.50", "10.1.255.255", "192.168.42.42"} {
+		if found, _ := f.Search(ip); !found {
+			t.Errorf("unexpected result for %s", ip)
+		}
+	}
+}
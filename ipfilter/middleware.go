@@ -0,0 +1,152 @@
+package ipfilter
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Mode is the mode of Middleware.
+type Mode int
+
+const (
+	// ModeDeny rejects the clients in the filter. It's the default mode.
+	ModeDeny Mode = iota
+
+	// ModeAllow rejects the clients not in the filter.
+	ModeAllow
+)
+
+// Middleware is an HTTP middleware which checks the client IPs against an IP
+// filter. The requests whose client IP cannot be determined are always
+// rejected.
+type Middleware struct {
+	// Filter is the filter to check the client IPs against.
+	Filter IPSearcher
+
+	// Mode decides if the clients in the Filter are rejected or allowed.
+	// ModeDeny will be used by default.
+	Mode Mode
+
+	// TrustedProxies are the reverse proxies whose forwarding headers are
+	// trusted, see ClientIP . The headers are never trusted if nil.
+	TrustedProxies IPSearcher
+
+	// ForwardedHeader is the header which the TrustedProxies set with the
+	// forwarding chain, such as "X-Forwarded-For", or "Forwarded" of RFC
+	// 7239. The other forwarding headers are ignored, as they may be sent by
+	// the clients. "X-Forwarded-For" will be used if empty.
+	ForwardedHeader string
+
+	// Rejected handles the rejected requests. A 403 Forbidden response will
+	// be sent if nil.
+	Rejected http.Handler
+}
+
+// Handler returns an http.Handler which calls the next handler only if the
+// client is accepted.
+func (m *Middleware) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := m.ForwardedHeader
+		if header == "" {
+			header = "X-Forwarded-For"
+		}
+		if m.accept(ClientIP(r, m.TrustedProxies, header)) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if m.Rejected != nil {
+			m.Rejected.ServeHTTP(w, r)
+			return
+		}
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+	})
+}
+
+func (m *Middleware) accept(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	found, err := m.Filter.SearchIP(ip)
+	if err != nil {
+		return false
+	}
+	return found == (m.Mode == ModeAllow)
+}
+
+// ClientIP returns the IP of the client which sent the request, or nil if
+// it cannot be determined.
+//
+// It's the IP of the RemoteAddr, unless the RemoteAddr is one of the trusted
+// proxies. In that case, the forwarding chain from the header, which is the
+// one set by the trusted proxies, such as "X-Forwarded-For" or "Forwarded",
+// is walked from the nearest hop, and the first IP which is not a trusted
+// proxy is the client IP. The "Forwarded" header is parsed as RFC 7239, and
+// the others as a comma-separated list of IPs. If the walk reaches an invalid
+// hop, such as "unknown", an empty one or an obfuscated identifier like
+// "_hidden", the client IP cannot be determined, and nil is returned.
+func ClientIP(r *http.Request, trusted IPSearcher, header string) net.IP {
+	ip := parseHop(r.RemoteAddr)
+	if ip == nil || !isTrusted(trusted, ip) {
+		return ip
+	}
+
+	var chain []string
+	if values := r.Header.Values(header); strings.EqualFold(header, "Forwarded") {
+		chain = parseForwarded(values)
+	} else {
+		for _, value := range values {
+			chain = append(chain, strings.Split(value, ",")...)
+		}
+	}
+
+	for i := len(chain) - 1; i >= 0; i-- {
+		if ip = parseHop(chain[i]); ip == nil || !isTrusted(trusted, ip) {
+			break
+		}
+	}
+	return ip
+}
+
+func isTrusted(trusted IPSearcher, ip net.IP) bool {
+	if trusted == nil {
+		return false
+	}
+	found, err := trusted.SearchIP(ip)
+	return found && err == nil
+}
+
+// parseForwarded returns the "for" parameters of the Forwarded headers in
+// order, see RFC 7239.
+func parseForwarded(values []string) (chain []string) {
+	for _, value := range values {
+		for _, element := range strings.Split(value, ",") {
+			hop := "" // an element without "for" is an invalid hop
+			for _, pair := range strings.Split(element, ";") {
+				kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+				if len(kv) == 2 && strings.EqualFold(kv[0], "for") {
+					hop = kv[1]
+				}
+			}
+			chain = append(chain, hop)
+		}
+	}
+	return
+}
+
+// parseHop parses a hop of the forwarding chain, which may be quoted, or have
+// a port, like "192.0.2.60", "192.0.2.60:4711" or "\"[2001:db8::17]:4711\"".
+func parseHop(hop string) net.IP {
+	hop = strings.Trim(strings.TrimSpace(hop), "\"")
+	if host, _, err := net.SplitHostPort(hop); err == nil {
+		hop = host
+	} else {
+		hop = strings.TrimSuffix(strings.TrimPrefix(hop, "["), "]")
+	}
+	// strip the zone of IPv6 addresses, like "fe80::1%eth0"
+	if i := strings.LastIndexByte(hop, '%'); i >= 0 {
+		hop = hop[:i]
+	}
+	return net.ParseIP(hop)
+}
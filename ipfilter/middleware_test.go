@@ -0,0 +1,139 @@
+package ipfilter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	trusted := new(IPFilter)
+	_ = trusted.Add("10.0.0.0/8")
+	_ = trusted.Add("2001:db8::/32")
+
+	const xff, fwd = "X-Forwarded-For", "Forwarded"
+	testcases := []struct {
+		remoteAddr string
+		header     string
+		headers    map[string][]string
+		expect     string
+	}{
+		{"192.0.2.1:1234", xff, nil, "192.0.2.1"},
+		{"invalid", xff, nil, "<nil>"},
+		// untrusted peer, the headers are ignored
+		{"192.0.2.1:1234", xff, map[string][]string{xff: {"198.51.100.1"}}, "192.0.2.1"},
+		// trusted peer
+		{"10.0.0.1:1234", xff, map[string][]string{xff: {"198.51.100.1"}}, "198.51.100.1"},
+		{"10.0.0.1:1234", xff, nil, "10.0.0.1"},
+		// spoofed hops before the first untrusted one are ignored
+		{"10.0.0.1:1234", xff, map[string][]string{xff: {"1.1.1.1, 198.51.100.1", "10.0.0.2"}}, "198.51.100.1"},
+		// all hops trusted
+		{"10.0.0.1:1234", xff, map[string][]string{xff: {"10.0.0.3, 10.0.0.2"}}, "10.0.0.3"},
+		// invalid hops
+		{"10.0.0.1:1234", xff, map[string][]string{xff: {"198.51.100.1, unknown, 10.0.0.2"}}, "<nil>"},
+		{"10.0.0.1:1234", xff, map[string][]string{xff: {"198.51.100.1, , 10.0.0.2"}}, "<nil>"},
+		{"10.0.0.1:1234", xff, map[string][]string{xff: {"unknown, 198.51.100.1"}}, "198.51.100.1"},
+		{"[2001:db8::1]:1234", fwd, map[string][]string{fwd: {"for=198.51.100.1, for=_hidden"}}, "<nil>"},
+		// the header not set by the proxies is injected by the client
+		{"10.0.0.1:1234", xff, map[string][]string{
+			fwd: {"for=8.8.8.8"},
+			xff: {"198.51.100.1"},
+		}, "198.51.100.1"},
+		{"[2001:db8::1]:1234", fwd, map[string][]string{
+			fwd: {`for=192.0.2.60;proto=http;by=203.0.113.43, for="[2001:db8:cafe::17]:4711"`},
+			xff: {"1.1.1.1"},
+		}, "192.0.2.60"},
+		{"[2001:db8::1]:1234", fwd, map[string][]string{xff: {"1.1.1.1"}}, "2001:db8::1"},
+		{"[2001:db8::1]:1234", fwd, map[string][]string{fwd: {`For="198.51.100.7:80"`}}, "198.51.100.7"},
+		{"[2001:db8::1]:1234", fwd, map[string][]string{fwd: {`proto=https`}}, "<nil>"},
+	}
+
+	for _, testcase := range testcases {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = testcase.remoteAddr
+		for key, values := range testcase.headers {
+			for _, value := range values {
+				r.Header.Add(key, value)
+			}
+		}
+		if ip := ClientIP(r, trusted, testcase.header).String(); ip != testcase.expect {
+			t.Errorf("unexpected client IP for %s %v, expect %s, got %s",
+				testcase.remoteAddr, testcase.headers, testcase.expect, ip)
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	f := new(IPFilter)
+	_ = f.Add("192.0.2.0/24")
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	testcases := []struct {
+		mode       Mode
+		rejected   http.Handler
+		remoteAddr string
+		expect     int
+	}{
+		{ModeDeny, nil, "192.0.2.1:1234", http.StatusForbidden},
+		{ModeDeny, nil, "198.51.100.1:1234", http.StatusNoContent},
+		{ModeDeny, nil, "invalid", http.StatusForbidden},
+		{ModeAllow, nil, "192.0.2.1:1234", http.StatusNoContent},
+		{ModeAllow, nil, "198.51.100.1:1234", http.StatusForbidden},
+		{ModeAllow, http.NotFoundHandler(), "198.51.100.1:1234", http.StatusNotFound},
+	}
+
+	for _, testcase := range testcases {
+		m := &Middleware{
+			Filter:   f,
+			Mode:     testcase.mode,
+			Rejected: testcase.rejected,
+		}
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = testcase.remoteAddr
+		w := httptest.NewRecorder()
+		m.Handler(next).ServeHTTP(w, r)
+		if w.Code != testcase.expect {
+			t.Errorf("unexpected status for %s in mode %d, expect %d, got %d",
+				testcase.remoteAddr, testcase.mode, testcase.expect, w.Code)
+		}
+	}
+}
+
+func TestMiddleware_ForwardedHeader(t *testing.T) {
+	f := new(IPFilter)
+	_ = f.Add("192.0.2.0/24")
+	trusted := new(IPFilter)
+	_ = trusted.Add("10.0.0.0/8")
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	testcases := []struct {
+		header string
+		expect int
+	}{
+		{"", http.StatusForbidden}, // X-Forwarded-For by default
+		{"X-Forwarded-For", http.StatusForbidden},
+		{"Forwarded", http.StatusNoContent},
+	}
+
+	for _, testcase := range testcases {
+		m := &Middleware{
+			Filter:          f,
+			TrustedProxies:  trusted,
+			ForwardedHeader: testcase.header,
+		}
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = "10.0.0.1:1234"
+		r.Header.Set("X-Forwarded-For", "192.0.2.1")
+		r.Header.Set("Forwarded", "for=198.51.100.1")
+		w := httptest.NewRecorder()
+		m.Handler(next).ServeHTTP(w, r)
+		if w.Code != testcase.expect {
+			t.Errorf("unexpected status for the header %q, expect %d, got %d",
+				testcase.header, testcase.expect, w.Code)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+//go:build go1.18
+// +build go1.18
+
+package ipfilter
+
+import (
+	"bytes"
+	"net"
+	"net/netip"
+)
+
+// AddPrefix adds a netip.Prefix into the filter. A prefix with host bits set
+// is handled according to the CIDRMode of the filter, just like Add.
+func (f *IPFilter) AddPrefix(p netip.Prefix) error {
+	if !p.IsValid() {
+		return &net.ParseError{Type: "IP prefix", Text: p.String()}
+	}
+
+	if masked := p.Masked(); masked != p {
+		if f.CIDRMode == CIDRStrict {
+			return &HostBitsError{Addr: p.String(), Network: masked.String()}
+		}
+		f.normalized = append(f.normalized, NormalizedCIDR{
+			Addr:    p.String(),
+			Network: masked.String(),
+		})
+		p = masked
+	}
+
+	bits := p.Bits()
+	if p.Addr().Is4() {
+		bits += (net.IPv6len - net.IPv4len) * 8
+	}
+	addr := p.Addr().As16()
+	min := make(net.IP, net.IPv6len)
+	copy(min, addr[:])
+	max := make(net.IP, net.IPv6len)
+	copy(max, min)
+	for i := bits; i < net.IPv6len*8; i++ {
+		max[i/8] |= 0x80 >> (i % 8)
+	}
+
+	f.tree.Insert(&cidr{
+		min: min,
+		max: max,
+	})
+	return nil
+}
+
+// AddRange adds all the IPs from the from to the to, both inclusive, into the
+// filter. The from and to must be in the same address family, and the from
+// must not be after the to.
+func (f *IPFilter) AddRange(from, to netip.Addr) error {
+	if !from.IsValid() || !to.IsValid() || from.Unmap().Is4() != to.Unmap().Is4() {
+		return &net.ParseError{Type: "IP range", Text: from.String() + "-" + to.String()}
+	}
+
+	min, max := from.As16(), to.As16()
+	if bytes.Compare(min[:], max[:]) > 0 {
+		return &net.ParseError{Type: "IP range", Text: from.String() + "-" + to.String()}
+	}
+
+	f.tree.Insert(&cidr{
+		min: net.IP(min[:]),
+		max: net.IP(max[:]),
+	})
+	return nil
+}
+
+// Contains checks if the addr is in the filter. It returns false for an
+// invalid addr. The CheckEmbeddedIPv4 of the filter applies as well.
+//
+// Unlike Search and SearchIP, it doesn't allocate memory.
+func (f *IPFilter) Contains(addr netip.Addr) bool {
+	if !addr.IsValid() {
+		return false
+	}
+
+	ip := addr.As16()
+	return f.contains(&ip)
+}
+
+// Contains checks if the addr is in the current filter, see
+// IPFilter.Contains .
+func (s *SafeIPFilter) Contains(addr netip.Addr) bool {
+	return s.Load().Contains(addr)
+}
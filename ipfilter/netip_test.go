@@ -0,0 +1,151 @@
+//go:build go1.18
+// +build go1.18
+
+package ipfilter
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/netip"
+	"testing"
+)
+
+func TestAddPrefix(t *testing.T) {
+	f := new(IPFilter)
+	for _, prefix := range []string{"10.0.0.0/8", "192.168.1.77/24", "2001:db8::/32", "::ffff:172.16.0.0/108"} {
+		if err := f.AddPrefix(netip.MustParsePrefix(prefix)); err != nil {
+			t.Fatalf("unexpected error for %s: %s", prefix, err)
+		}
+	}
+	if err := f.AddPrefix(netip.Prefix{}); err == nil {
+		t.Fatal("expect an error for an invalid prefix")
+	}
+
+	testcases := []struct {
+		ip     string
+		expect bool
+	}{
+		{"10.1.2.3", true},
+		{"11.0.0.0", false},
+		{"192.168.1.0", true},
+		{"192.168.1.255", true},
+		{"192.168.2.0", false},
+		{"172.31.255.255", true},
+		{"172.32.0.0", false},
+		{"2001:db8:ffff::1", true},
+		{"2001:db9::", false},
+	}
+	for _, testcase := range testcases {
+		if found := f.Contains(netip.MustParseAddr(testcase.ip)); found != testcase.expect {
+			t.Errorf("unexpected result for %s, expect %v, got %v", testcase.ip, testcase.expect, found)
+		}
+	}
+
+	normalized := f.Normalized()
+	if len(normalized) != 1 || normalized[0].Network != "192.168.1.0/24" {
+		t.Fatalf("unexpected normalized prefixes: %v", normalized)
+	}
+
+	f = &IPFilter{CIDRMode: CIDRStrict}
+	var hostBitsErr *HostBitsError
+	if err := f.AddPrefix(netip.MustParsePrefix("192.168.1.77/24")); !errors.As(err, &hostBitsErr) {
+		t.Fatalf("expect a HostBitsError, got %v", err)
+	}
+}
+
+func TestAddRange(t *testing.T) {
+	f := new(IPFilter)
+	if err := f.AddRange(netip.MustParseAddr("10.0.0.1"), netip.MustParseAddr("10.0.0.50")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := f.AddRange(netip.MustParseAddr("2001:db8::1"), netip.MustParseAddr("2001:db8::ff")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	invalids := [][2]netip.Addr{
+		{netip.MustParseAddr("10.0.0.50"), netip.MustParseAddr("10.0.0.1")},
+		{netip.MustParseAddr("10.0.0.1"), netip.MustParseAddr("2001:db8::1")},
+		{netip.Addr{}, netip.MustParseAddr("10.0.0.1")},
+	}
+	for _, item := range invalids {
+		if err := f.AddRange(item[0], item[1]); err == nil {
+			t.Errorf("expect an error for %s-%s", item[0], item[1])
+		}
+	}
+
+	testcases := []struct {
+		ip     string
+		expect bool
+	}{
+		{"10.0.0.0", false},
+		{"10.0.0.1", true},
+		{"::ffff:10.0.0.50", true},
+		{"10.0.0.51", false},
+		{"2001:db8::ff", true},
+		{"2001:db8::100", false},
+	}
+	for _, testcase := range testcases {
+		if found := f.Contains(netip.MustParseAddr(testcase.ip)); found != testcase.expect {
+			t.Errorf("unexpected result for %s, expect %v, got %v", testcase.ip, testcase.expect, found)
+		}
+	}
+	if f.Contains(netip.Addr{}) {
+		t.Error("expect false for an invalid addr")
+	}
+}
+
+func TestContains_noAlloc(t *testing.T) {
+	f := NewNonPublicIPFilter()
+	addr := netip.MustParseAddr("192.168.1.1")
+	allocs := testing.AllocsPerRun(100, func() {
+		f.Contains(addr)
+	})
+	if allocs != 0 {
+		t.Fatalf("expect no allocation, got %v", allocs)
+	}
+}
+
+func BenchmarkContains(b *testing.B) {
+	f := NewNonPublicIPFilter()
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		addr := netip.AddrFrom4([4]byte{
+			byte(rand.Intn(256)), byte(rand.Intn(256)), byte(rand.Intn(256)), byte(rand.Intn(256))})
+
+		for pb.Next() {
+			f.Contains(addr)
+		}
+	})
+}
+
+func BenchmarkSearchIP(b *testing.B) {
+	f := NewNonPublicIPFilter()
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		ip := net.ParseIP(fmt.Sprintf("%d.%d.%d.%d",
+			rand.Intn(256), rand.Intn(256), rand.Intn(256), rand.Intn(256)))
+
+		for pb.Next() {
+			f.SearchIP(ip)
+		}
+	})
+}
+
+func TestContains_checkEmbeddedIPv4(t *testing.T) {
+	f := NewNonPublicIPFilter()
+	f.CheckEmbeddedIPv4 = true
+	addr := netip.MustParseAddr("64:ff9b::7f00:1")
+	if !f.Contains(addr) {
+		t.Fatalf("expect %s to be found", addr)
+	}
+	allocs := testing.AllocsPerRun(100, func() {
+		f.Contains(addr)
+	})
+	if allocs != 0 {
+		t.Fatalf("expect no allocation, got %v", allocs)
+	}
+}
@@ -0,0 +1,110 @@
+package ipfilter
+
+import (
+	"bytes"
+	"net"
+	"strings"
+)
+
+// parseRange parses a range notation, like "10.0.0.1-10.0.0.50".
+func parseRange(addr string) (*cidr, error) {
+	parts := strings.Split(addr, "-")
+	if len(parts) != 2 {
+		return nil, &net.ParseError{Type: "IP range", Text: addr}
+	}
+
+	min := net.ParseIP(strings.TrimSpace(parts[0]))
+	max := net.ParseIP(strings.TrimSpace(parts[1]))
+	if min == nil || max == nil || (min.To4() == nil) != (max.To4() == nil) {
+		return nil, &net.ParseError{Type: "IP range", Text: addr}
+	}
+
+	min, max = min.To16(), max.To16()
+	if bytes.Compare(min, max) > 0 {
+		return nil, &net.ParseError{Type: "IP range", Text: addr}
+	}
+	return &cidr{
+		min: min,
+		max: max,
+	}, nil
+}
+
+// parseWildcard parses a wildcard notation, like "192.168.*.*".
+func parseWildcard(addr string) (*cidr, error) {
+	sep, all := ".", "255"
+	if strings.ContainsRune(addr, ':') {
+		sep, all = ":", "ffff"
+	}
+
+	components := strings.Split(addr, sep)
+	minComponents := make([]string, len(components))
+	maxComponents := make([]string, len(components))
+	for i, c := range components {
+		minComponents[i], maxComponents[i] = c, c
+		if c == "*" {
+			minComponents[i], maxComponents[i] = "0", all
+		}
+	}
+
+	min := net.ParseIP(strings.Join(minComponents, sep)).To16()
+	max := net.ParseIP(strings.Join(maxComponents, sep)).To16()
+	if min == nil || max == nil || !isPrefixRange(min, max) {
+		return nil, &net.ParseError{Type: "IP wildcard", Text: addr}
+	}
+	return &cidr{
+		min: min,
+		max: max,
+	}, nil
+}
+
+// isPrefixRange returns true if [min, max] covers exactly a CIDR prefix,
+// which means the wildcards are all trailing.
+func isPrefixRange(min, max net.IP) bool {
+	hostBits := false
+	for i := range min {
+		diff := min[i] ^ max[i]
+		if min[i]&diff != 0 || max[i]&diff != diff {
+			return false
+		}
+		if hostBits && diff != 0xff {
+			return false
+		}
+		if diff != 0 {
+			// diff must be like 0b00011111
+			if diff&(diff+1) != 0 {
+				return false
+			}
+			hostBits = true
+		}
+	}
+	return true
+}
+
+// parseNetmask parses an address with a netmask, like "10.0.0.0 255.255.0.0".
+func parseNetmask(addr string) (net.IP, *net.IPNet, error) {
+	fields := strings.Fields(addr)
+	ip, mask := net.ParseIP(fields[0]), net.ParseIP(fields[1])
+	if ip == nil || mask == nil {
+		return nil, nil, &net.ParseError{Type: "IP address with netmask", Text: addr}
+	}
+
+	if ip4 := ip.To4(); ip4 != nil {
+		ip, mask = ip4, mask.To4()
+	} else if mask.To4() != nil && !strings.ContainsRune(fields[1], ':') {
+		// IPv6 address with an IPv4 netmask
+		mask = nil
+	}
+	if mask == nil {
+		return nil, nil, &net.ParseError{Type: "IP address with netmask", Text: addr}
+	}
+
+	ones, bits := net.IPMask(mask).Size()
+	if ones == 0 && bits == 0 {
+		// non-canonical netmask
+		return nil, nil, &net.ParseError{Type: "IP address with netmask", Text: addr}
+	}
+	return ip, &net.IPNet{
+		IP:   ip.Mask(net.CIDRMask(ones, bits)),
+		Mask: net.CIDRMask(ones, bits),
+	}, nil
+}
@@ -0,0 +1,54 @@
+package ipfilter
+
+import "testing"
+
+func TestAdd_notations(t *testing.T) {
+	testcases := []struct {
+		addr    string
+		error   bool
+		matched []string
+		missed  []string
+	}{
+		{"10.0.0.1-10.0.0.50", false, []string{"10.0.0.1", "10.0.0.50"}, []string{"10.0.0.0", "10.0.0.51"}},
+		{"10.0.0.1 - 10.0.0.50", false, []string{"10.0.0.25"}, nil},
+		{"2001:db8::1-2001:db8::ff", false, []string{"2001:db8::1", "2001:db8::ff"}, []string{"2001:db8::", "2001:db8::100"}},
+		{"10.0.0.50-10.0.0.1", true, nil, nil},
+		{"10.0.0.1-2001:db8::1", true, nil, nil},
+		{"10.0.0.1-10.0.0.2-10.0.0.3", true, nil, nil},
+		{"192.168.*.*", false, []string{"192.168.0.0", "192.168.255.255"}, []string{"192.169.0.0"}},
+		{"*.*.*.*", false, []string{"0.0.0.0", "255.255.255.255", "::ffff:1.2.3.4"}, []string{"::1"}},
+		{"2001:db8:*:*:*:*:*:*", false, []string{"2001:db8::1", "2001:db8:ffff::"}, []string{"2001:db9::"}},
+		{"2001:db8::*", false, []string{"2001:db8::ffff"}, []string{"2001:db8::1:0"}},
+		{"192.*.1.*", true, nil, nil},
+		{"192.168.1*.*", true, nil, nil},
+		{"10.0.0.0 255.255.0.0", false, []string{"10.0.255.255"}, []string{"10.1.0.0"}},
+		{"10.0.3.4 255.255.255.0", false, []string{"10.0.3.0", "10.0.3.255"}, []string{"10.0.4.0"}},
+		{"2001:db8:: ffff:ffff::", false, []string{"2001:db8:ffff::"}, []string{"2001:db9::"}},
+		{"10.0.0.0 255.0.255.0", true, nil, nil},
+		{"10.0.0.0 ffff::", true, nil, nil},
+		{"2001:db8:: 255.255.0.0", true, nil, nil},
+	}
+
+	for _, testcase := range testcases {
+		f := new(IPFilter)
+		if err := f.Add(testcase.addr); testcase.error != (err != nil) {
+			t.Errorf("unexpected error for %s: %v", testcase.addr, err)
+			continue
+		}
+		for _, ip := range testcase.matched {
+			if found, _ := f.Search(ip); !found {
+				t.Errorf("%s is not matched by %s", ip, testcase.addr)
+			}
+		}
+		for _, ip := range testcase.missed {
+			if found, _ := f.Search(ip); found {
+				t.Errorf("%s is unexpectedly matched by %s", ip, testcase.addr)
+			}
+		}
+	}
+
+	f := &IPFilter{CIDRMode: CIDRStrict}
+	if err := f.Add("10.0.3.4 255.255.255.0"); err == nil {
+		t.Error("expect an error for the netmask notation with host bits set in strict mode")
+	}
+}
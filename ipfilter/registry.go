@@ -0,0 +1,158 @@
+package ipfilter
+
+// Category is the category of a special-purpose address block, see Classify .
+type Category int
+
+// The categories of the special-purpose address blocks.
+const (
+	// CategoryInvalid is for the invalid IPs.
+	CategoryInvalid Category = iota
+
+	// CategoryPublic is for the IPs not in any special-purpose address block.
+	CategoryPublic
+
+	CategoryUnspecified // 0.0.0.0/32, ::/128
+	CategoryThisNetwork // 0.0.0.0/8
+	CategoryLoopback    // 127.0.0.0/8, ::1/128
+	CategoryPrivate     // 10.0.0.0/8, 172.16.0.0/12, 192.168.0.0/16
+	CategoryUniqueLocal // fc00::/7
+
+	// CategorySharedAddress is for the shared address space for CGNAT,
+	// 100.64.0.0/10.
+	CategorySharedAddress
+
+	CategoryLinkLocal     // 169.254.0.0/16, fe80::/10
+	CategoryMulticast     // 224.0.0.0/4, ff00::/8
+	CategoryBroadcast     // 255.255.255.255/32
+	CategoryDocumentation // 192.0.2.0/24, 2001:db8::/32, etc.
+	CategoryBenchmarking  // 198.18.0.0/15, 2001:2::/48
+	CategoryReserved      // 240.0.0.0/4
+	CategoryDiscardOnly   // 100::/64
+
+	// CategoryProtocolAssignment is for the IETF protocol assignments, such
+	// as 192.0.0.0/24, 2001::/23 and the AS112 blocks.
+	CategoryProtocolAssignment
+
+	// CategoryTranslation is for the IPv4/IPv6 translation, such as
+	// 64:ff9b::/96.
+	CategoryTranslation
+
+	// CategoryTransition is for the IPv6 transition mechanisms, such as
+	// 6to4 2002::/16, Teredo 2001::/32 and DS-Lite 192.0.0.0/29.
+	CategoryTransition
+
+	CategoryORCHID // 2001:10::/28, 2001:20::/28
+
+	// CategorySRv6 is for the Segment Routing over IPv6 (SRv6) Segment
+	// Identifiers, 5f00::/16.
+	CategorySRv6
+)
+
+var categoryNames = []string{
+	CategoryInvalid:            "invalid",
+	CategoryPublic:             "public",
+	CategoryUnspecified:        "unspecified",
+	CategoryThisNetwork:        "this network",
+	CategoryLoopback:           "loopback",
+	CategoryPrivate:            "private",
+	CategoryUniqueLocal:        "unique local",
+	CategorySharedAddress:      "shared address",
+	CategoryLinkLocal:          "link local",
+	CategoryMulticast:          "multicast",
+	CategoryBroadcast:          "broadcast",
+	CategoryDocumentation:      "documentation",
+	CategoryBenchmarking:       "benchmarking",
+	CategoryReserved:           "reserved",
+	CategoryDiscardOnly:        "discard only",
+	CategoryProtocolAssignment: "protocol assignment",
+	CategoryTranslation:        "translation",
+	CategoryTransition:         "transition",
+	CategoryORCHID:             "ORCHID",
+	CategorySRv6:               "SRv6",
+}
+
+// String returns the name of the category.
+func (c Category) String() string {
+	if c < 0 || int(c) >= len(categoryNames) {
+		return "unknown"
+	}
+	return categoryNames[c]
+}
+
+type specialBlock struct {
+	prefix   string
+	category Category
+
+	// global is the "Globally Reachable" of the IANA registries.
+	global bool
+}
+
+// specialBlocks are the blocks of the IANA IPv4 and IPv6 Special-Purpose
+// Address Registries, see RFC 6890 and its updates, plus the multicast blocks.
+//
+// The IP4-mapped IPv6 addresses, ::ffff:0:0/96, are not listed, as they are
+// handled as the IPv4 addresses.
+var specialBlocks = []specialBlock{
+	{"0.0.0.0/8", CategoryThisNetwork, false},
+	{"0.0.0.0/32", CategoryUnspecified, false},
+	{"10.0.0.0/8", CategoryPrivate, false},
+	{"100.64.0.0/10", CategorySharedAddress, false},
+	{"127.0.0.0/8", CategoryLoopback, false},
+	{"169.254.0.0/16", CategoryLinkLocal, false},
+	{"172.16.0.0/12", CategoryPrivate, false},
+	{"192.0.0.0/24", CategoryProtocolAssignment, false},
+	{"192.0.0.0/29", CategoryTransition, false},         // DS-Lite
+	{"192.0.0.8/32", CategoryProtocolAssignment, false}, // IPv4 dummy address
+	{"192.0.0.9/32", CategoryProtocolAssignment, true},  // PCP Anycast
+	{"192.0.0.10/32", CategoryProtocolAssignment, true}, // TURN Anycast
+	{"192.0.0.170/31", CategoryTranslation, false},      // NAT64/DNS64 Discovery
+	{"192.0.2.0/24", CategoryDocumentation, false},
+	{"192.31.196.0/24", CategoryProtocolAssignment, true}, // AS112-v4
+	{"192.52.193.0/24", CategoryProtocolAssignment, true}, // AMT
+	{"192.88.99.0/24", CategoryTransition, false},         // deprecated 6to4 Relay Anycast
+	{"192.168.0.0/16", CategoryPrivate, false},
+	{"192.175.48.0/24", CategoryProtocolAssignment, true}, // Direct Delegation AS112
+	{"198.18.0.0/15", CategoryBenchmarking, false},
+	{"198.51.100.0/24", CategoryDocumentation, false},
+	{"203.0.113.0/24", CategoryDocumentation, false},
+	{"224.0.0.0/4", CategoryMulticast, false},
+	{"240.0.0.0/4", CategoryReserved, false},
+	{"255.255.255.255/32", CategoryBroadcast, false},
+
+	{"::/128", CategoryUnspecified, false},
+	{"::1/128", CategoryLoopback, false},
+	{"64:ff9b::/96", CategoryTranslation, true},
+	{"64:ff9b:1::/48", CategoryTranslation, false},
+	{"100::/64", CategoryDiscardOnly, false},
+	{"100:0:0:1::/64", CategoryProtocolAssignment, false}, // Dummy IPv6 Prefix
+	{"2001::/23", CategoryProtocolAssignment, false},
+	{"2001::/32", CategoryTransition, false},            // Teredo
+	{"2001:1::1/128", CategoryProtocolAssignment, true}, // PCP Anycast
+	{"2001:1::2/128", CategoryProtocolAssignment, true}, // TURN Anycast
+	{"2001:1::3/128", CategoryProtocolAssignment, true}, // DNS-SD SRP Anycast
+	{"2001:2::/48", CategoryBenchmarking, false},
+	{"2001:3::/32", CategoryProtocolAssignment, true},     // AMT
+	{"2001:4:112::/48", CategoryProtocolAssignment, true}, // AS112-v6
+	{"2001:10::/28", CategoryORCHID, false},               // deprecated
+	{"2001:20::/28", CategoryORCHID, true},                // ORCHIDv2
+	{"2001:30::/28", CategoryProtocolAssignment, true},    // Drone Remote ID Protocol Entity Tags
+	{"2001:db8::/32", CategoryDocumentation, false},
+	{"2002::/16", CategoryTransition, false},                // 6to4
+	{"2620:4f:8000::/48", CategoryProtocolAssignment, true}, // Direct Delegation AS112
+	{"3fff::/20", CategoryDocumentation, false},
+	{"5f00::/16", CategorySRv6, false},
+	{"fc00::/7", CategoryUniqueLocal, false},
+	{"fe80::/10", CategoryLinkLocal, false},
+	{"ff00::/8", CategoryMulticast, false},
+}
+
+// NewSpecialPurposeIPFilter returns a new IPFilter which can filter out all
+// the IPs in the IANA Special-Purpose Address Registries, see RFC 6890, and
+// the multicast IPs.
+func NewSpecialPurposeIPFilter() *IPFilter {
+	f := new(IPFilter)
+	for _, block := range specialBlocks {
+		_ = f.Add(block.prefix)
+	}
+	return f
+}
@@ -0,0 +1,42 @@
+package ipfilter
+
+import (
+	"testing"
+)
+
+func TestNewSpecialPurposeIPFilter(t *testing.T) {
+	f := NewSpecialPurposeIPFilter()
+	testcases := []struct {
+		ip     string
+		expect bool
+	}{
+		{"64:ff9b::8.8.8.8", true},
+		{"192.31.196.1", true},
+		{"2001:20::1", true},
+		{"2001:30::1", true},
+		{"2620:4f:8000::1", true},
+		{"5f00:1::1", true},
+		{"100:0:0:1::1", true},
+		{"192.0.2.1", true},
+		{"8.8.8.8", false},
+		{"2001:4860::8888", false},
+	}
+	for _, testcase := range testcases {
+		found, err := f.Search(testcase.ip)
+		if err != nil {
+			t.Fatalf("unexpected error for %s: %s", testcase.ip, err)
+		}
+		if found != testcase.expect {
+			t.Errorf("unexpected result for %s, expect %v, got %v", testcase.ip, testcase.expect, found)
+		}
+	}
+}
+
+func TestCategory_String(t *testing.T) {
+	if s := CategoryLoopback.String(); s != "loopback" {
+		t.Fatalf("unexpected name for CategoryLoopback: %s", s)
+	}
+	if s := Category(-1).String(); s != "unknown" {
+		t.Fatalf("unexpected name for an unknown category: %s", s)
+	}
+}
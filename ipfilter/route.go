@@ -0,0 +1,204 @@
+//go:build go1.18
+// +build go1.18
+
+package ipfilter
+
+import (
+	"bytes"
+	"net"
+	"strings"
+
+	"github.com/sym01/algo/avl"
+)
+
+// RouteTable is a routing table which keeps the nested prefixes, such as
+// 10.0.0.0/8 and 10.1.0.0/16 with different values, and looks up the most
+// specific prefix for an address.
+//
+// The IPv4 and IPv6 prefixes are kept separately, so an IPv4 address never
+// matches an IPv6 prefix such as "::/0", and vice versa.
+//
+// It's thread-safe for read ops. But if you need to read and write at the same
+// time, a RWLock is necessary.
+type RouteTable[V any] struct {
+	tree avl.Tree
+
+	// number of the prefixes for each prefix length
+	lengths4 [net.IPv4len*8 + 1]int
+	lengths6 [net.IPv6len*8 + 1]int
+}
+
+type route[V any] struct {
+	v4    bool
+	ip    [net.IPv6len]byte // the network address, in a 16-bytes representation
+	bits  int
+	value V
+}
+
+// Compare implements avl.Range . The routes are ordered by the address
+// family, the network address, and then the prefix length.
+func (l *route[V]) Compare(right avl.Range) int {
+	r := right.(*route[V])
+	if l.v4 != r.v4 {
+		if l.v4 {
+			return -1
+		}
+		return 1
+	}
+	if factor := bytes.Compare(l.ip[:], r.ip[:]); factor != 0 {
+		return factor
+	}
+	switch {
+	case l.bits < r.bits:
+		return -1
+	case l.bits > r.bits:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// Contains implements avl.Range .
+func (l *route[V]) Contains(right avl.Range) bool {
+	return l.Compare(right) == 0
+}
+
+// Union implements avl.Range .
+func (l *route[V]) Union(right avl.Range) avl.Range {
+	return l
+}
+
+func (l *route[V]) prefix() *net.IPNet {
+	if l.v4 {
+		return &net.IPNet{
+			IP:   net.IP(l.ip[12:]).To4(),
+			Mask: net.CIDRMask(l.bits, net.IPv4len*8),
+		}
+	}
+	return &net.IPNet{
+		IP:   append(net.IP(nil), l.ip[:]...),
+		Mask: net.CIDRMask(l.bits, net.IPv6len*8),
+	}
+}
+
+// newRoute returns a route for the ip with the prefix length bits, and the
+// host bits are cleared.
+func newRoute[V any](ip net.IP, bits int) *route[V] {
+	r := &route[V]{bits: bits}
+	if ip4 := ip.To4(); ip4 != nil {
+		r.v4 = true
+		copy(r.ip[:], ip4.Mask(net.CIDRMask(bits, net.IPv4len*8)).To16())
+	} else {
+		copy(r.ip[:], ip.Mask(net.CIDRMask(bits, net.IPv6len*8)))
+	}
+	return r
+}
+
+// parseRoute parses the prefix as a CIDR address or a single IP, and the host
+// bits are cleared. The IPv4-mapped IPv6 prefixes are converted to IPv4.
+func parseRoute[V any](prefix string) (*route[V], error) {
+	if !strings.ContainsRune(prefix, '/') {
+		ip := net.ParseIP(prefix)
+		if ip == nil {
+			return nil, &net.ParseError{Type: "IP address", Text: prefix}
+		}
+		if ip.To4() != nil {
+			return newRoute[V](ip, net.IPv4len*8), nil
+		}
+		return newRoute[V](ip, net.IPv6len*8), nil
+	}
+
+	_, ipNet, err := net.ParseCIDR(prefix)
+	if err != nil {
+		return nil, err
+	}
+	ones, bits := ipNet.Mask.Size()
+	if bits == net.IPv6len*8 && ipNet.IP.To4() != nil {
+		// an IPv4-mapped prefix, which is at least /96
+		ones -= (net.IPv6len - net.IPv4len) * 8
+	}
+	return newRoute[V](ipNet.IP, ones), nil
+}
+
+func (t *RouteTable[V]) lengths(r *route[V]) []int {
+	if r.v4 {
+		return t.lengths4[:]
+	}
+	return t.lengths6[:]
+}
+
+// Insert a prefix with the value into the table, or update the value if the
+// prefix already exists. The prefix can be a CIDR address, like "10.0.0.0/8",
+// or a single IP, which is treated as "/32" or "/128". The host bits of the
+// prefix are cleared. An IPv4-mapped IPv6 prefix, like "::ffff:10.0.0.0/104",
+// is the same as the IPv4 one, like "10.0.0.0/8".
+func (t *RouteTable[V]) Insert(prefix string, value V) error {
+	r, err := parseRoute[V](prefix)
+	if err != nil {
+		return err
+	}
+
+	if old, ok := t.tree.Get(r); ok {
+		old.(*route[V]).value = value
+		return nil
+	}
+	r.value = value
+	t.tree.Insert(r)
+	t.lengths(r)[r.bits]++
+	return nil
+}
+
+// Delete removes the prefix from the table, and returns false if not found.
+// The prefix accepts the same notations as Insert.
+func (t *RouteTable[V]) Delete(prefix string) (bool, error) {
+	r, err := parseRoute[V](prefix)
+	if err != nil {
+		return false, err
+	}
+
+	if !t.tree.Delete(r) {
+		return false, nil
+	}
+	t.lengths(r)[r.bits]--
+	return true, nil
+}
+
+// Lookup returns the most specific prefix containing the ip, and its value.
+// It returns false if no prefix contains the ip.
+func (t *RouteTable[V]) Lookup(ip net.IP) (prefix *net.IPNet, value V, ok bool) {
+	lengths := t.lengths6[:]
+	if ip.To4() != nil {
+		lengths = t.lengths4[:]
+	} else if ip.To16() == nil {
+		return
+	}
+
+	for bits := len(lengths) - 1; bits >= 0; bits-- {
+		if lengths[bits] == 0 {
+			continue
+		}
+		if val, found := t.tree.Get(newRoute[V](ip, bits)); found {
+			r := val.(*route[V])
+			return r.prefix(), r.value, true
+		}
+	}
+	return
+}
+
+// Walk calls fn for every prefix in the table in order, until fn returns
+// false. The IPv4 prefixes come first, and a prefix always comes before the
+// more specific prefixes it contains.
+func (t *RouteTable[V]) Walk(fn func(prefix *net.IPNet, value V) bool) {
+	c := t.tree.Cursor()
+	for ok := c.First(); ok; ok = c.Next() {
+		r := c.Value().(*route[V])
+		if !fn(r.prefix(), r.value) {
+			return
+		}
+	}
+}
+
+// Len returns the number of prefixes in the table.
+func (t *RouteTable[V]) Len() int {
+	return t.tree.Len()
+}
@@ -0,0 +1,179 @@
+//go:build go1.18
+// +build go1.18
+
+package ipfilter
+
+import (
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestRouteTable(t *testing.T) {
+	var rt RouteTable[string]
+	inserts := []struct {
+		prefix string
+		value  string
+	}{
+		{"0.0.0.0/0", "default"},
+		{"10.0.0.0/8", "corp"},
+		{"10.1.0.0/16", "lab"},
+		{"10.1.2.3", "host"},
+		{"::/0", "default6"},
+		{"2001:db8::/32", "docs"},
+		{"2001:db8:1::/48", "docs-lab"},
+	}
+	for _, item := range inserts {
+		if err := rt.Insert(item.prefix, item.value); err != nil {
+			t.Fatalf("unexpected error for %s: %s", item.prefix, err)
+		}
+	}
+	if err := rt.Insert("10.0.0.0/33", "x"); err == nil {
+		t.Fatal("expect an error for an invalid prefix")
+	}
+	if rt.Len() != len(inserts) {
+		t.Fatalf("expect %d prefixes, got %d", len(inserts), rt.Len())
+	}
+
+	testcases := []struct {
+		ip     string
+		found  bool
+		value  string
+		prefix string
+	}{
+		{"10.0.0.1", true, "corp", "10.0.0.0/8"},
+		{"10.1.0.1", true, "lab", "10.1.0.0/16"},
+		{"10.1.2.3", true, "host", "10.1.2.3/32"},
+		{"::ffff:10.1.2.3", true, "host", "10.1.2.3/32"},
+		{"8.8.8.8", true, "default", "0.0.0.0/0"},
+		{"2001:db8::1", true, "docs", "2001:db8::/32"},
+		{"2001:db8:1::1", true, "docs-lab", "2001:db8:1::/48"},
+		{"2001:4860::8888", true, "default6", "::/0"},
+	}
+	for _, item := range testcases {
+		prefix, value, found := rt.Lookup(net.ParseIP(item.ip))
+		if found != item.found {
+			t.Fatalf("expect %v for %s, got %v", item.found, item.ip, found)
+		}
+		if !found {
+			continue
+		}
+		if value != item.value || prefix.String() != item.prefix {
+			t.Fatalf("expect %s %s for %s, got %s %s", item.prefix, item.value, item.ip, prefix, value)
+		}
+	}
+
+	// update
+	if err := rt.Insert("10.1.255.255/16", "lab2"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, value, _ := rt.Lookup(net.ParseIP("10.1.0.1")); value != "lab2" {
+		t.Fatalf("expect the value to be updated, got %s", value)
+	}
+	if rt.Len() != len(inserts) {
+		t.Fatalf("expect %d prefixes after update, got %d", len(inserts), rt.Len())
+	}
+
+	// delete
+	if ok, err := rt.Delete("10.1.0.0/16"); !ok || err != nil {
+		t.Fatalf("expect 10.1.0.0/16 to be deleted, got %v %v", ok, err)
+	}
+	if ok, _ := rt.Delete("10.1.0.0/16"); ok {
+		t.Fatal("expect false when deleting a missing prefix")
+	}
+	if _, err := rt.Delete("invalid"); err == nil {
+		t.Fatal("expect an error for an invalid prefix")
+	}
+	if prefix, _, _ := rt.Lookup(net.ParseIP("10.1.0.1")); prefix.String() != "10.0.0.0/8" {
+		t.Fatalf("expect 10.0.0.0/8 after delete, got %s", prefix)
+	}
+	if _, _, found := rt.Lookup(net.IP{1, 2, 3}); found {
+		t.Fatal("expect no match for an invalid ip")
+	}
+}
+
+func TestRouteTable_familySeparated(t *testing.T) {
+	var rt RouteTable[int]
+	_ = rt.Insert("::/0", 6)
+	if _, _, found := rt.Lookup(net.ParseIP("1.2.3.4")); found {
+		t.Fatal("expect an IPv4 address not to match ::/0")
+	}
+	_ = rt.Insert("0.0.0.0/0", 4)
+	if _, value, _ := rt.Lookup(net.ParseIP("1.2.3.4")); value != 4 {
+		t.Fatalf("expect 4, got %d", value)
+	}
+	if _, value, _ := rt.Lookup(net.ParseIP("::1")); value != 6 {
+		t.Fatalf("expect 6, got %d", value)
+	}
+}
+
+func TestRouteTable_v4Mapped(t *testing.T) {
+	var rt RouteTable[string]
+	inserts := []struct {
+		prefix string
+		value  string
+	}{
+		{"::ffff:0.0.0.0/96", "default"},
+		{"::ffff:10.0.0.0/104", "corp"},
+		{"::ffff:10.1.2.3/128", "host"},
+		{"::fffe:0:0/95", "v6"}, // not IPv4-mapped
+	}
+	for _, item := range inserts {
+		if err := rt.Insert(item.prefix, item.value); err != nil {
+			t.Fatalf("unexpected error for %s: %s", item.prefix, err)
+		}
+	}
+
+	testcases := []struct {
+		ip     string
+		value  string
+		prefix string
+	}{
+		{"8.8.8.8", "default", "0.0.0.0/0"},
+		{"10.0.0.1", "corp", "10.0.0.0/8"},
+		{"::ffff:10.1.2.3", "host", "10.1.2.3/32"},
+		{"::fffe:1:1", "v6", "::fffe:0:0/95"},
+	}
+	for _, item := range testcases {
+		prefix, value, found := rt.Lookup(net.ParseIP(item.ip))
+		if !found || value != item.value || prefix.String() != item.prefix {
+			t.Fatalf("expect %s %s for %s, got %s %s", item.prefix, item.value, item.ip, prefix, value)
+		}
+	}
+
+	if ok, err := rt.Delete("10.0.0.0/8"); !ok || err != nil {
+		t.Fatalf("expect ::ffff:10.0.0.0/104 to be deleted as 10.0.0.0/8, got %v %v", ok, err)
+	}
+	if ok, err := rt.Delete("::ffff:0.0.0.0/96"); !ok || err != nil {
+		t.Fatalf("expect ::ffff:0.0.0.0/96 to be deleted, got %v %v", ok, err)
+	}
+	if _, _, found := rt.Lookup(net.ParseIP("10.0.0.1")); found {
+		t.Fatal("expect no match after delete")
+	}
+}
+
+func TestRouteTable_Walk(t *testing.T) {
+	var rt RouteTable[int]
+	for i, prefix := range []string{"2001:db8::/32", "10.1.0.0/16", "10.0.0.0/8", "192.168.0.0/16", "10.1.0.0/24"} {
+		_ = rt.Insert(prefix, i)
+	}
+
+	var got []string
+	rt.Walk(func(prefix *net.IPNet, value int) bool {
+		got = append(got, prefix.String())
+		return true
+	})
+	expected := []string{"10.0.0.0/8", "10.1.0.0/16", "10.1.0.0/24", "192.168.0.0/16", "2001:db8::/32"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expect %v, got %v", expected, got)
+	}
+
+	got = got[:0]
+	rt.Walk(func(prefix *net.IPNet, value int) bool {
+		got = append(got, prefix.String())
+		return len(got) < 2
+	})
+	if !reflect.DeepEqual(got, expected[:2]) {
+		t.Fatalf("expect %v, got %v", expected[:2], got)
+	}
+}
@@ -0,0 +1,69 @@
+package ipfilter
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+)
+
+// SafeIPFilter is a concurrency-safe IPFilter which can be reloaded at run
+// time. The searches are lock-free, and always see a complete filter: a new
+// filter is built aside, and then swapped in atomically.
+//
+// The zero value is an empty filter ready to use.
+type SafeIPFilter struct {
+	mu     sync.Mutex   // serializes the reloads
+	filter atomic.Value // *IPFilter
+}
+
+// emptyFilter is the filter of a zero SafeIPFilter, which is never modified.
+var emptyFilter = new(IPFilter)
+
+// NewSafeIPFilter returns a new SafeIPFilter serving f. The f must not be
+// modified anymore, a nil f means an empty filter.
+func NewSafeIPFilter(f *IPFilter) *SafeIPFilter {
+	s := new(SafeIPFilter)
+	if f != nil {
+		s.filter.Store(f)
+	}
+	return s
+}
+
+// Load returns the current filter. The returned filter must not be modified.
+func (s *SafeIPFilter) Load() *IPFilter {
+	if f, ok := s.filter.Load().(*IPFilter); ok {
+		return f
+	}
+	return emptyFilter
+}
+
+// Reload builds a new filter with the build, and swaps it in if the build
+// succeeds. The build is called with an empty filter with the same options,
+// CIDRMode and CheckEmbeddedIPv4, as the current one. If the build returns an
+// error, the current filter is kept and the error is returned.
+//
+// The searches are served by the current filter while building, and the
+// concurrent reloads are serialized.
+func (s *SafeIPFilter) Reload(build func(*IPFilter) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cur := s.Load()
+	f := &IPFilter{CIDRMode: cur.CIDRMode, CheckEmbeddedIPv4: cur.CheckEmbeddedIPv4}
+	if err := build(f); err != nil {
+		return err
+	}
+	s.filter.Store(f)
+	return nil
+}
+
+// Search parses addr as an IP address, and checks if it's in the current
+// filter, see IPFilter.Search .
+func (s *SafeIPFilter) Search(addr string) (bool, error) {
+	return s.Load().Search(addr)
+}
+
+// SearchIP checks if the ip is in the current filter, see IPFilter.SearchIP .
+func (s *SafeIPFilter) SearchIP(ip net.IP) (bool, error) {
+	return s.Load().SearchIP(ip)
+}
@@ -0,0 +1,85 @@
+package ipfilter
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"testing"
+)
+
+func TestSafeIPFilter(t *testing.T) {
+	var s SafeIPFilter
+	if found, err := s.Search("10.0.0.1"); found || err != nil {
+		t.Fatalf("unexpected result for an empty filter, got %v, %v", found, err)
+	}
+
+	if err := s.Reload(func(f *IPFilter) error {
+		return f.Add("10.0.0.0/8")
+	}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if found, _ := s.SearchIP(net.ParseIP("10.0.0.1")); !found {
+		t.Fatal("expect 10.0.0.1 to be found after reload")
+	}
+
+	// a failed reload keeps the current filter
+	errBuild := errors.New("build failed")
+	if err := s.Reload(func(f *IPFilter) error {
+		_ = f.Add("192.168.0.0/16")
+		return errBuild
+	}); err != errBuild {
+		t.Fatalf("expect the build error, got %v", err)
+	}
+	if found, _ := s.Search("10.0.0.1"); !found {
+		t.Fatal("expect the old filter to be kept")
+	}
+	if found, _ := s.Search("192.168.0.1"); found {
+		t.Fatal("expect the failed filter not to be swapped in")
+	}
+
+	// a reload replaces the whole filter
+	_ = s.Reload(func(f *IPFilter) error {
+		return f.Add("192.168.0.0/16")
+	})
+	if found, _ := s.Search("10.0.0.1"); found {
+		t.Fatal("expect 10.0.0.1 to be gone after reload")
+	}
+}
+
+func TestSafeIPFilter_cidrMode(t *testing.T) {
+	s := NewSafeIPFilter(&IPFilter{CIDRMode: CIDRStrict})
+	err := s.Reload(func(f *IPFilter) error {
+		return f.Add("192.168.1.77/24")
+	})
+	var hostBitsErr *HostBitsError
+	if !errors.As(err, &hostBitsErr) {
+		t.Fatalf("expect a HostBitsError, got %v", err)
+	}
+}
+
+func TestSafeIPFilter_concurrent(t *testing.T) {
+	s := NewSafeIPFilter(NewNonPublicIPFilter())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				if found, _ := s.Search("127.0.0.1"); !found {
+					t.Error("expect 127.0.0.1 to be always found")
+					return
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				_ = s.Reload(func(f *IPFilter) error {
+					return f.Add("127.0.0.0/8")
+				})
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,118 @@
+package ipfilter
+
+import (
+	"bytes"
+	"net"
+)
+
+// Family is an address family, which is used by IPFilter.Complement .
+type Family int
+
+const (
+	// IPv4 is the family of the IPv4 and IP4-mapped IPv6 addresses.
+	IPv4 Family = iota
+
+	// IPv6 is the family of all the IPv6 addresses, except the IP4-mapped
+	// IPv6 addresses.
+	IPv6
+)
+
+// Union returns a new IPFilter with the IPs in f or other.
+func (f *IPFilter) Union(other *IPFilter) *IPFilter {
+	ret := f.fromRanges(f.ranges())
+	for _, r := range other.ranges() {
+		ret.tree.Insert(r)
+	}
+	return ret
+}
+
+// Intersect returns a new IPFilter with the IPs in both f and other.
+func (f *IPFilter) Intersect(other *IPFilter) *IPFilter {
+	return f.fromRanges(intersect(f.ranges(), other.ranges()))
+}
+
+// Subtract returns a new IPFilter with the IPs in f but not in other.
+// For instance, a policy "deny list minus allow list" can be expressed as
+// deny.Subtract(allow).
+func (f *IPFilter) Subtract(other *IPFilter) *IPFilter {
+	return f.fromRanges(subtract(f.ranges(), other.ranges()))
+}
+
+// Complement returns a new IPFilter with the IPs of the family which are not
+// in f.
+func (f *IPFilter) Complement(family Family) *IPFilter {
+	universe := []*cidr{{min: v4MappedMin, max: v4MappedMax}}
+	if family == IPv6 {
+		universe = []*cidr{
+			{min: make(net.IP, net.IPv6len), max: prevIP(v4MappedMin)},
+			{min: nextIP(v4MappedMax), max: net.IP(bytes.Repeat([]byte{0xff}, net.IPv6len))},
+		}
+	}
+	return f.fromRanges(subtract(universe, f.ranges()))
+}
+
+// ranges returns the disjoint ranges of the filter in order.
+func (f *IPFilter) ranges() []*cidr {
+	var ret []*cidr
+	c := f.tree.Cursor()
+	for ok := c.First(); ok; ok = c.Next() {
+		ret = append(ret, c.Value().(*cidr))
+	}
+	return ret
+}
+
+// fromRanges returns a new IPFilter with the ranges and the same options as
+// f.
+func (f *IPFilter) fromRanges(ranges []*cidr) *IPFilter {
+	ret := &IPFilter{CIDRMode: f.CIDRMode, CheckEmbeddedIPv4: f.CheckEmbeddedIPv4}
+	for _, r := range ranges {
+		ret.tree.Insert(r)
+	}
+	return ret
+}
+
+// intersect returns the overlapping parts of two sorted, disjoint lists of
+// ranges.
+func intersect(left, right []*cidr) (ret []*cidr) {
+	for i, j := 0, 0; i < len(left) && j < len(right); {
+		l, r := left[i], right[j]
+		if min, max := maxIP(l.min, r.min), minIP(l.max, r.max); bytes.Compare(min, max) <= 0 {
+			ret = append(ret, &cidr{min: min, max: max})
+		}
+
+		if bytes.Compare(l.max, r.max) < 0 {
+			i++
+		} else {
+			j++
+		}
+	}
+	return
+}
+
+// subtract returns the parts of left which are not in right, both of which
+// are sorted, disjoint lists of ranges.
+func subtract(left, right []*cidr) (ret []*cidr) {
+	j := 0
+	for _, l := range left {
+		cur := l.min
+		for ; j < len(right) && bytes.Compare(right[j].max, cur) < 0; j++ {
+		}
+
+		for ; j < len(right) && bytes.Compare(right[j].min, l.max) <= 0; j++ {
+			r := right[j]
+			if bytes.Compare(r.min, cur) > 0 {
+				ret = append(ret, &cidr{min: cur, max: prevIP(r.min)})
+			}
+			if bytes.Compare(r.max, l.max) >= 0 {
+				// r may overlap the next range of left as well
+				cur = nil
+				break
+			}
+			cur = nextIP(r.max)
+		}
+		if cur != nil {
+			ret = append(ret, &cidr{min: cur, max: l.max})
+		}
+	}
+	return
+}
@@ -0,0 +1,92 @@
+package ipfilter
+
+import (
+	"testing"
+)
+
+func newTestFilter(t *testing.T, addrs ...string) *IPFilter {
+	f := new(IPFilter)
+	for _, addr := range addrs {
+		if err := f.Add(addr); err != nil {
+			t.Fatalf("unexpected error for %s: %s", addr, err)
+		}
+	}
+	return f
+}
+
+func checkFilter(t *testing.T, name string, f *IPFilter, expected map[string]bool) {
+	for ip, expect := range expected {
+		found, err := f.Search(ip)
+		if err != nil {
+			t.Fatalf("unexpected error for %s: %s", ip, err)
+		}
+		if found != expect {
+			t.Errorf("unexpected result of %s for %s, expect %v, got %v", name, ip, expect, found)
+		}
+	}
+}
+
+func TestIPFilter_setOps(t *testing.T) {
+	deny := newTestFilter(t, "10.0.0.0/8", "192.168.0.0/16", "2001:db8::/32")
+	allow := newTestFilter(t, "10.1.0.0/16", "10.3.0.0/16", "192.168.255.0-192.169.0.255", "172.16.0.0/12")
+
+	checkFilter(t, "Union", deny.Union(allow), map[string]bool{
+		"10.2.0.1":    true,
+		"172.16.0.1":  true,
+		"192.169.0.1": true,
+		"192.169.1.1": false,
+		"2001:db8::1": true,
+	})
+	checkFilter(t, "Intersect", deny.Intersect(allow), map[string]bool{
+		"10.0.0.1":      false,
+		"10.1.0.1":      true,
+		"10.3.255.255":  true,
+		"172.16.0.1":    false,
+		"192.168.255.1": true,
+		"192.169.0.1":   false,
+		"2001:db8::1":   false,
+	})
+	checkFilter(t, "Subtract", deny.Subtract(allow), map[string]bool{
+		"10.0.255.255":    true,
+		"10.1.0.0":        false,
+		"10.1.255.255":    false,
+		"10.2.0.0":        true,
+		"10.3.0.1":        false,
+		"10.4.0.0":        true,
+		"192.168.254.255": true,
+		"192.168.255.0":   false,
+		"172.16.0.1":      false,
+		"2001:db8::1":     true,
+	})
+
+	// the operands are untouched
+	checkFilter(t, "deny", deny, map[string]bool{"10.1.0.1": true, "172.16.0.1": false})
+	checkFilter(t, "allow", allow, map[string]bool{"10.1.0.1": true, "10.2.0.1": false})
+}
+
+func TestIPFilter_Complement(t *testing.T) {
+	f := newTestFilter(t, "0.0.0.0", "10.0.0.0/8", "255.255.255.255", "::", "2001:db8::/32")
+
+	checkFilter(t, "Complement(IPv4)", f.Complement(IPv4), map[string]bool{
+		"0.0.0.0":         false,
+		"0.0.0.1":         true,
+		"9.255.255.255":   true,
+		"10.1.0.1":        false,
+		"11.0.0.0":        true,
+		"255.255.255.254": true,
+		"255.255.255.255": false,
+		"2001:db9::":      false,
+	})
+	checkFilter(t, "Complement(IPv6)", f.Complement(IPv6), map[string]bool{
+		"::":          false,
+		"::1":         true,
+		"1.1.1.1":     false,
+		"2001:db8::1": false,
+		"2001:db9::":  true,
+		"ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff": true,
+	})
+
+	if ret := new(IPFilter).Complement(IPv4).Complement(IPv4); ret.tree.Len() != 0 {
+		t.Fatalf("expect an empty filter, got %d ranges", ret.tree.Len())
+	}
+}